@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//in batch mode the input contains many manifests one after the other. each of them starts with a line containing
+//the number of weights, followed by lines of weights until that number is reached. a manifest can't end in the middle
+//of a line, which is how we check the count: the values are also allowed to span several lines, so a wrong count
+//would otherwise silently shift every following manifest. blank lines are ignored
+
+type manifest struct {
+	number  int //counted from 1
+	line    int //line of the header
+	weights []float64
+}
+
+//reads the manifests from r and calls handle on each of them as soon as it is complete, so that we never hold more
+//than one manifest in memory
+func readManifests(r io.Reader, handle func(m manifest) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 100), 64000000) //a single line of weights can be very long
+
+	current := manifest{}
+	expected := -1 //number of weights of the current manifest, -1 when we are waiting for a header
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if expected == -1 { //this is a header
+			n, err := strconv.Atoi(line)
+			if err != nil || n < 0 {
+				return fmt.Errorf("line %d: expected the number of weights of manifest %d, got %q",
+					lineNumber, current.number+1, line)
+			}
+			current = manifest{number: current.number + 1, line: lineNumber, weights: make([]float64, 0, n)}
+			expected = n
+		} else {
+			for i, value := range splitValues(line) {
+				f, err := parseWeight(value.text)
+				if err != nil {
+					return fmt.Errorf("line %d: %v", lineNumber, value.errorf(i, "%v", err))
+				}
+				current.weights = append(current.weights, f)
+			}
+			if len(current.weights) > expected {
+				return fmt.Errorf("line %d: manifest %d announces %d weights on line %d but has %d",
+					lineNumber, current.number, expected, current.line, len(current.weights))
+			}
+		}
+
+		if len(current.weights) == expected {
+			if err := handle(current); err != nil {
+				return fmt.Errorf("manifest %d (line %d): %v", current.number, current.line, err)
+			}
+			expected = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if expected != -1 {
+		return fmt.Errorf("manifest %d announces %d weights on line %d but the input ends after %d of them",
+			current.number, expected, current.line, len(current.weights))
+	}
+	return nil
+}
+
+//prints the total of each manifest and then a summary of the whole batch
+func runBatch(in io.Reader, out io.Writer, unit weightUnit) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "manifest\tweights\tfuel\t")
+
+	manifests, weights := 0, 0
+	totalFuel := 0.
+	err := readManifests(in, func(m manifest) error {
+		if err := covers(currentModel, m.weights); err != nil {
+			return err
+		}
+		fuel := totalFuelRequired(m.weights)
+		fmt.Fprintf(tw, "%d\t%d\t%v\t\n", m.number, len(m.weights), fuel/unit.kilograms)
+		manifests++
+		weights += len(m.weights)
+		totalFuel += fuel
+		return nil
+	})
+	if err != nil {
+		tw.Flush() //we still print the manifests which were complete
+		return err
+	}
+
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "\nmanifests %d\nweights %d\ntotal %v\n", manifests, weights, totalFuel/unit.kilograms)
+	return err
+}
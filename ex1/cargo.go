@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//the cargo selection answers another question with the same input: given a fuel budget, which items should we take
+//to carry as much as possible? each item costs fuelRequired(weight) and "as much as possible" is either the highest
+//total weight or the highest number of items, depending on the objective
+
+const (
+	objectiveWeight = "weight"
+	objectiveCount  = "count"
+)
+
+//the exact solver is only used when the fuel costs can be scaled to integers with at most this many decimals
+const maxCostDecimals = 6
+
+type cargoSelection struct {
+	items  []int //indices of the chosen weights, in increasing order
+	weight float64
+	fuel   float64
+	method string //"exact" or "greedy"
+}
+
+//chooses the items to carry without spending more than budget. the dynamic programming solver is exact but needs
+//len(weights) * budget cells, so when this exceeds maxCells we fall back to a greedy approximation
+func selectCargo(weights []float64, budget float64, objective string, maxCells int) (cargoSelection, error) {
+	if objective != objectiveWeight && objective != objectiveCount {
+		return cargoSelection{}, fmt.Errorf("unknown objective %q, expected %s or %s",
+			objective, objectiveWeight, objectiveCount)
+	}
+
+	costs := make([]float64, len(weights))
+	values := make([]float64, len(weights))
+	for i, w := range weights {
+		costs[i] = fuelRequired(w)
+		if costs[i] < 0 {
+			return cargoSelection{}, fmt.Errorf("weight %v has a negative fuel cost", w)
+		}
+		values[i] = 1
+		if objective == objectiveWeight {
+			values[i] = w
+		}
+	}
+
+	var selection cargoSelection
+	if scaled, scaledBudget, ok := scaleCosts(costs, budget); ok && len(costs)*(scaledBudget+1) <= maxCells {
+		selection = cargoSelection{items: knapsackExact(scaled, values, scaledBudget), method: "exact"}
+	} else {
+		selection = cargoSelection{items: knapsackGreedy(costs, values, budget), method: "greedy"}
+	}
+
+	for _, i := range selection.items {
+		selection.weight += weights[i]
+		selection.fuel += costs[i]
+	}
+	return selection, nil
+}
+
+//converts the costs to integers by multiplying them by the smallest power of 10 which makes all of them whole
+//numbers, the budget is scaled too and rounded down as a partial unit of fuel can't pay for anything
+func scaleCosts(costs []float64, budget float64) (scaled []int, scaledBudget int, ok bool) {
+	sum := 0.
+	for _, c := range costs {
+		sum += c
+	}
+	if budget > sum { //we can't spend more than the cost of everything, this keeps the table small
+		budget = sum
+	}
+
+	scale := 1.
+	for decimals := 0; decimals <= maxCostDecimals; decimals, scale = decimals+1, scale*10 {
+		if budget*scale > float64(math.MaxInt32) {
+			return nil, 0, false
+		}
+		scaled = make([]int, len(costs))
+		whole := true
+		for i, c := range costs {
+			rounded := math.Round(c * scale)
+			if math.Abs(rounded-c*scale) > 1e-6 {
+				whole = false
+				break
+			}
+			scaled[i] = int(rounded)
+		}
+		if whole {
+			return scaled, int(math.Floor(budget*scale + 1e-6)), true
+		}
+	}
+	return nil, 0, false
+}
+
+//classic 0/1 knapsack: best[c] is the best value reachable with a cost of at most c using the items seen so far,
+//and taken[i][c] remembers whether item i was used to reach best[c] so that we can find the items back
+func knapsackExact(costs []int, values []float64, budget int) []int {
+	best := make([]float64, budget+1)
+	taken := make([][]bool, len(costs))
+	for i, cost := range costs {
+		taken[i] = make([]bool, budget+1)
+		if values[i] <= 0 { //these items can never improve the selection
+			continue
+		}
+		for c := budget; c >= cost; c-- { //we go backwards so that each item is used at most once
+			if with := best[c-cost] + values[i]; with > best[c] {
+				best[c] = with
+				taken[i][c] = true
+			}
+		}
+	}
+
+	items := make([]int, 0)
+	c := budget
+	for i := len(costs) - 1; i >= 0; i-- { //we walk back through the table to find which items were taken
+		if taken[i][c] {
+			items = append(items, i)
+			c -= costs[i]
+		}
+	}
+	sort.Ints(items)
+	return items
+}
+
+//takes the items by decreasing value per unit of fuel while they fit in the budget. as this alone can be arbitrarily
+//bad, we return the best single item instead when it is worth more, which guarantees at least half of the optimum
+func knapsackGreedy(costs []float64, values []float64, budget float64) []int {
+	order := make([]int, 0, len(costs))
+	for i := range costs {
+		if values[i] > 0 && costs[i] <= budget {
+			order = append(order, i)
+		}
+	}
+	ratio := func(i int) float64 {
+		if costs[i] == 0 {
+			return math.Inf(1)
+		}
+		return values[i] / costs[i]
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ratio(order[a]) > ratio(order[b])
+	})
+
+	items := make([]int, 0)
+	spent, total := 0., 0.
+	bestSingle := -1
+	for _, i := range order {
+		if spent+costs[i] <= budget {
+			items = append(items, i)
+			spent += costs[i]
+			total += values[i]
+		}
+		if bestSingle == -1 || values[i] > values[bestSingle] {
+			bestSingle = i
+		}
+	}
+	if bestSingle != -1 && values[bestSingle] > total {
+		items = []int{bestSingle}
+	}
+	sort.Ints(items)
+	return items
+}
+
+func (s cargoSelection) write(out io.Writer) error {
+	indices := make([]string, 0, len(s.items)+1)
+	indices = append(indices, "items")
+	for _, item := range s.items {
+		indices = append(indices, strconv.Itoa(item))
+	}
+	_, err := fmt.Fprintf(out, "%s\ncount %d\nweight %v\nfuel %v\nmethod %s\n",
+		strings.Join(indices, " "), len(s.items), s.weight, s.fuel, s.method)
+	return err
+}
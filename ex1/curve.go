@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//a fuelModel tells how much fuel a weight requires. the weights are also classified in bands, which are the bands of
+//a rule table or the segments between the control points of a curve, so that reports can group them
+type fuelModel interface {
+	fuel(weight float64) (float64, error)
+	band(weight float64) (int, error)
+	bandNames() []string
+}
+
+//the model used by fuelRequired, main replaces it when a rule table or a curve is given on the command line
+var currentModel fuelModel = defaultRuleTable()
+
+//checks that the model can give the fuel required by every weight, so that fuelRequired never has to fail
+func covers(model fuelModel, weights []float64) error {
+	for _, w := range weights {
+		if _, err := model.fuel(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	interpolationLinear = "linear"
+	interpolationSpline = "spline"
+
+	extrapolationClamp  = "clamp"
+	extrapolationLinear = "linear"
+	extrapolationError  = "error"
+)
+
+//a fuelCurve is a continuous alternative to the step functions of rule tables: the fuel is given at a few weights,
+//the control points, and interpolated in between. the spline is a monotone cubic Hermite spline: it is smooth but,
+//unlike a natural spline, it never goes above or below the control points between them, so a curve which always
+//increases can't require less fuel for a heavier weight
+type fuelCurve struct {
+	weights       []float64 //strictly increasing
+	fuels         []float64
+	slopes        []float64 //tangent at each control point, used by the spline
+	interpolation string
+	extrapolation string //what to do with weights outside of the control points
+}
+
+//reads a curve from a file made of the two settings and one control point per line:
+//	interpolation linear|spline
+//	extrapolation clamp|linear|error
+//	weight fuel
+//everything following a '#' is a comment. both settings are optional and default to linear and clamp
+func loadFuelCurve(path string) (*fuelCurve, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	curve := &fuelCurve{interpolation: interpolationLinear, extrapolation: extrapolationClamp}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected two values", path, lineNumber)
+		}
+
+		switch fields[0] {
+		case "interpolation":
+			curve.interpolation = fields[1]
+		case "extrapolation":
+			curve.extrapolation = fields[1]
+		default:
+			weight, _, err := parseNumber(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid weight %q", path, lineNumber, fields[0])
+			}
+			fuel, _, err := parseNumber(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid fuel %q", path, lineNumber, fields[1])
+			}
+			curve.weights = append(curve.weights, weight)
+			curve.fuels = append(curve.fuels, fuel)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = curve.prepare(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return curve, nil
+}
+
+//checks the settings and the control points, and computes the tangents of the spline
+func (c *fuelCurve) prepare() error {
+	if c.interpolation != interpolationLinear && c.interpolation != interpolationSpline {
+		return fmt.Errorf("unknown interpolation %q, expected %s or %s",
+			c.interpolation, interpolationLinear, interpolationSpline)
+	}
+	if c.extrapolation != extrapolationClamp && c.extrapolation != extrapolationLinear &&
+		c.extrapolation != extrapolationError {
+		return fmt.Errorf("unknown extrapolation %q, expected %s, %s or %s",
+			c.extrapolation, extrapolationClamp, extrapolationLinear, extrapolationError)
+	}
+	if len(c.weights) < 2 {
+		return fmt.Errorf("a curve needs at least two control points")
+	}
+	for i := 1; i < len(c.weights); i++ {
+		if c.weights[i] <= c.weights[i-1] {
+			return fmt.Errorf("the weights of the control points must be strictly increasing, %v comes after %v",
+				c.weights[i], c.weights[i-1])
+		}
+	}
+
+	//Fritsch-Carlson: we start from the slopes of the segments and limit the tangents so that the spline stays
+	//monotone on each segment
+	n := len(c.weights)
+	secants := make([]float64, n-1)
+	for i := range secants {
+		secants[i] = (c.fuels[i+1] - c.fuels[i]) / (c.weights[i+1] - c.weights[i])
+	}
+	c.slopes = make([]float64, n)
+	c.slopes[0], c.slopes[n-1] = secants[0], secants[n-2]
+	for i := 1; i < n-1; i++ {
+		if secants[i-1]*secants[i] <= 0 { //local extremum, the curve must be flat there
+			c.slopes[i] = 0
+		} else {
+			c.slopes[i] = (secants[i-1] + secants[i]) / 2
+		}
+	}
+	for i, secant := range secants {
+		if secant == 0 {
+			c.slopes[i], c.slopes[i+1] = 0, 0
+			continue
+		}
+		a, b := c.slopes[i]/secant, c.slopes[i+1]/secant
+		if s := a*a + b*b; s > 9 {
+			t := 3 / math.Sqrt(s)
+			c.slopes[i], c.slopes[i+1] = t*a*secant, t*b*secant
+		}
+	}
+	return nil
+}
+
+//returns the index of the segment containing weight: 0 is before the first control point, i is between the
+//control points i-1 and i, and len(weights) is after the last one
+func (c *fuelCurve) band(weight float64) (int, error) {
+	if math.IsNaN(weight) {
+		return -1, fmt.Errorf("weight %v can't be classified", weight)
+	}
+	//control points belong to the segment on their left, except for the first one
+	segment := sort.SearchFloat64s(c.weights, weight)
+	if segment == 0 && weight == c.weights[0] {
+		segment = 1
+	}
+	if (segment == 0 || segment == len(c.weights)) && c.extrapolation == extrapolationError {
+		return -1, fmt.Errorf("weight %v is outside of the curve [%v, %v]",
+			weight, c.weights[0], c.weights[len(c.weights)-1])
+	}
+	return segment, nil
+}
+
+func (c *fuelCurve) bandNames() []string {
+	n := len(c.weights)
+	names := make([]string, n+1)
+	names[0] = "below " + strconv.FormatFloat(c.weights[0], 'g', -1, 64)
+	for i := 1; i < n; i++ {
+		names[i] = "[" + strconv.FormatFloat(c.weights[i-1], 'g', -1, 64) + ", " +
+			strconv.FormatFloat(c.weights[i], 'g', -1, 64) + "]"
+	}
+	names[n] = "above " + strconv.FormatFloat(c.weights[n-1], 'g', -1, 64)
+	return names
+}
+
+func (c *fuelCurve) fuel(weight float64) (float64, error) {
+	segment, err := c.band(weight)
+	if err != nil {
+		return 0, err
+	}
+	n := len(c.weights)
+
+	switch segment {
+	case 0, n: //we are outside of the control points
+		end := 0
+		if segment == n {
+			end = n - 1
+		}
+		if c.extrapolation == extrapolationClamp {
+			return c.fuels[end], nil
+		}
+		slope := c.slopes[end]
+		if c.interpolation == interpolationLinear {
+			slope = (c.fuels[1] - c.fuels[0]) / (c.weights[1] - c.weights[0])
+			if end != 0 {
+				slope = (c.fuels[n-1] - c.fuels[n-2]) / (c.weights[n-1] - c.weights[n-2])
+			}
+		}
+		return c.fuels[end] + slope*(weight-c.weights[end]), nil
+	}
+
+	x0, x1 := c.weights[segment-1], c.weights[segment]
+	y0, y1 := c.fuels[segment-1], c.fuels[segment]
+	h := x1 - x0
+	t := (weight - x0) / h
+	if c.interpolation == interpolationLinear {
+		return y0 + t*(y1-y0), nil
+	}
+	//cubic Hermite basis functions
+	t2, t3 := t*t, t*t*t
+	return (2*t3-3*t2+1)*y0 + (t3-2*t2+t)*h*c.slopes[segment-1] +
+		(-2*t3+3*t2)*y1 + (t3-t2)*h*c.slopes[segment], nil
+}
+
+//parses a sampling range written from:to:step
+func parseSampling(spec string) (from, to, step float64, err error) {
+	fields := strings.Split(spec, ":")
+	if len(fields) != 3 {
+		return 0, 0, 0, fmt.Errorf("sampling %q must be written from:to:step", spec)
+	}
+	values := make([]float64, 3)
+	for i, str := range fields {
+		if values[i], _, err = parseNumber(str); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid number %q in sampling %q", str, spec)
+		}
+	}
+	from, to, step = values[0], values[1], values[2]
+	if step <= 0 || to < from {
+		return 0, 0, 0, fmt.Errorf("sampling %q must go up with a positive step", spec)
+	}
+	return from, to, step, nil
+}
+
+//prints the fuel required by weights going from from to to, so that a model can be checked by eye or plotted
+func sampleModel(out io.Writer, model fuelModel, from, to, step float64) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "weight\tfuel\tband\t")
+	names := model.bandNames()
+	for i := 0; ; i++ {
+		w := from + float64(i)*step //we don't accumulate the step to avoid drifting
+		if w > to+step*1e-9 {
+			break
+		}
+		fuel, err := model.fuel(w)
+		if err != nil {
+			fmt.Fprintf(tw, "%v\t-\t%v\t\n", w, err)
+			continue
+		}
+		band, _ := model.band(w)
+		fmt.Fprintf(tw, "%v\t%v\t%s\t\n", w, fuel, names[band])
+	}
+	return tw.Flush()
+}
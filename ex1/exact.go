@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+//the exact mode does the same thing as getAndParseInput, fuelRequired and totalFuelRequired but with rationals, so
+//that no weight is misclassified near a bound and summing many values doesn't accumulate rounding errors
+
+func (r fuelRule) containsExact(weight *big.Rat) bool {
+	aboveLow := r.lowExact == nil //a nil bound is infinite
+	if !aboveLow {
+		c := weight.Cmp(r.lowExact)
+		aboveLow = c > 0 || (r.lowInclusive && c == 0)
+	}
+	belowHigh := r.highExact == nil
+	if !belowHigh {
+		c := weight.Cmp(r.highExact)
+		belowHigh = c < 0 || (r.highInclusive && c == 0)
+	}
+	return aboveLow && belowHigh
+}
+
+func (r fuelRule) costExact(weight *big.Rat) *big.Rat {
+	cost := new(big.Rat).Mul(r.perKgExact, weight)
+	return cost.Add(cost, r.fixedExact)
+}
+
+func (t ruleTable) bandExact(weight *big.Rat) (int, error) {
+	for i, r := range t {
+		if r.containsExact(weight) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("weight %s is not covered by any band of the rule table", weight.RatString())
+}
+
+func (t ruleTable) coversExact(weights []*big.Rat) error {
+	for _, w := range weights {
+		if _, err := t.bandExact(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getAndParseExactInput() (inputRat []*big.Rat, err error) {
+	inputRat = []*big.Rat{}
+	values, err := getInputValues()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		r, err := parseWeightExact(value.text)
+		if err != nil {
+			return nil, value.errorf(i, "%v", err)
+		}
+		inputRat = append(inputRat, r)
+	}
+	return inputRat, nil
+}
+
+func (t ruleTable) totalFuelRequiredExact(weights []*big.Rat) *big.Rat {
+	totalFuel := new(big.Rat)
+	for _, w := range weights {
+		totalFuel.Add(totalFuel, t.fuelRequiredExact(w))
+	}
+	return totalFuel
+}
+
+func (t ruleTable) fuelRequiredExact(weight *big.Rat) *big.Rat {
+	i, err := t.bandExact(weight)
+	if err != nil { //main checks the weights before calling us so this should never happen
+		panic(err)
+	}
+	return t[i].costExact(weight)
+}
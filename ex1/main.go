@@ -2,39 +2,209 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"math/big"
 	"os"
-	"strconv"
 	"strings"
 )
 
 func main() {
-	weights := getAndParseInput() //we read from stdin and convert it to a slice of floats each containing
+	rulesPath := flag.String("rules", "", "read the fuel rule table from this file instead of using the default one")
+	recursive := flag.Bool("recursive", false, "also carry the fuel required by the fuel itself and print the "+
+		"fixed point found for each weight")
+	density := flag.Float64("density", 1, "mass of one unit of fuel, used by -recursive")
+	maxIterations := flag.Int("max-iterations", 100, "maximum number of iterations per weight, used by -recursive")
+	reportFormat := flag.String("report", "", "print the fuel used by each weight and by each band as json, csv "+
+		"or text instead of the total only")
+	exact := flag.Bool("exact", false, "parse, classify and sum the weights with exact rationals")
+	precision := flag.Int("precision", 6, "number of decimals printed in -exact mode")
+	budget := flag.Float64("budget", 0, "instead of the total, print which items to carry without spending more "+
+		"than this amount of fuel")
+	objective := flag.String("objective", objectiveWeight, "what -budget maximises: weight or count")
+	maxCells := flag.Int("max-cells", 10000000, "largest table the exact -budget solver may use before falling "+
+		"back to a greedy one")
+	vehicleSpec := flag.String("vehicles", "", "split the weights between these vehicles, written "+
+		"name:capacity:overhead[:maximum trips] and separated by commas, and print the fuel used by each of them")
+	unitName := flag.String("unit", "kg", "unit of the printed masses, one of kg, t or lb. it is only supported by "+
+		"the total, -exact, -report, -batch and -stats")
+	batch := flag.Bool("batch", false, "read many manifests, each made of a line with the number of weights "+
+		"followed by the weights, and print the total of each of them")
+	curvePath := flag.String("curve", "", "read a piecewise linear or spline fuel curve from this file and use it "+
+		"instead of a rule table")
+	sampling := flag.String("sample", "", "print the fuel required by weights going from:to:step instead of "+
+		"reading any input, to check a rule table or a curve")
+	stats := flag.Bool("stats", false, "print statistics about the weights and a histogram of the bands they "+
+		"belong to before the total")
+	percentileSpec := flag.String("percentiles", "10,25,75,90,99", "percentiles printed by -stats")
+	barWidth := flag.Int("bar-width", 40, "length of the longest bar of the -stats histogram")
+	flag.Parse()
+
+	outputUnit, err := findUnit(*unitName)
+	exitOnError(err)
+	if *unitName != "kg" && (*recursive || isFlagSet("budget") || *vehicleSpec != "") {
+		exitOnError(fmt.Errorf("-unit can't be combined with -recursive, -budget or -vehicles"))
+	}
+	percentiles, err := parsePercentiles(*percentileSpec)
+	exitOnError(err)
+	if *stats && (*exact || *recursive || *reportFormat != "" || isFlagSet("budget") || *vehicleSpec != "" ||
+		*batch) {
+		exitOnError(fmt.Errorf("-stats can't be combined with -exact, -recursive, -report, -budget, -vehicles or " +
+			"-batch"))
+	}
+	if *barWidth < 1 {
+		exitOnError(fmt.Errorf("-bar-width must be at least 1"))
+	}
+	if *batch && (*exact || *recursive || *reportFormat != "" || isFlagSet("budget") || *vehicleSpec != "") {
+		exitOnError(fmt.Errorf("-batch can't be combined with -exact, -recursive, -report, -budget or -vehicles"))
+	}
+
+	if *density < 0 || *maxIterations < 1 {
+		exitOnError(fmt.Errorf("-density must be positive and -max-iterations at least 1"))
+	}
+	if *exact && (*recursive || *reportFormat != "") {
+		exitOnError(fmt.Errorf("-exact can't be combined with -recursive or -report"))
+	}
+	if *reportFormat != "" && *recursive {
+		exitOnError(fmt.Errorf("-report can't be combined with -recursive"))
+	}
+	if *precision < 0 {
+		exitOnError(fmt.Errorf("-precision can't be negative"))
+	}
+	if isFlagSet("budget") && (*budget < 0 || *exact || *recursive || *reportFormat != "") {
+		exitOnError(fmt.Errorf("-budget must be positive and can't be combined with -exact, -recursive or -report"))
+	}
+	var vehicles []vehicle
+	if *vehicleSpec != "" {
+		if *exact || *recursive || *reportFormat != "" || isFlagSet("budget") {
+			exitOnError(fmt.Errorf("-vehicles can't be combined with -exact, -recursive, -report or -budget"))
+		}
+		vehicles, err = parseVehicles(*vehicleSpec)
+		exitOnError(err)
+	}
+
+	switch {
+	case *rulesPath != "" && *curvePath != "":
+		exitOnError(fmt.Errorf("-rules and -curve can't be used together"))
+	case *rulesPath != "":
+		table, err := loadRuleTable(*rulesPath)
+		exitOnError(err)
+		currentModel = table
+	case *curvePath != "":
+		curve, err := loadFuelCurve(*curvePath)
+		exitOnError(err)
+		currentModel = curve
+	}
+
+	if *sampling != "" { //this mode doesn't read any input
+		if *unitName != "kg" || *recursive || *reportFormat != "" || *exact || isFlagSet("budget") ||
+			*vehicleSpec != "" || *stats || *batch {
+			exitOnError(fmt.Errorf("-sample can't be combined with -unit, -recursive, -report, -exact, -budget, " +
+				"-vehicles, -stats or -batch"))
+		}
+		from, to, step, err := parseSampling(*sampling)
+		exitOnError(err)
+		exitOnError(sampleModel(os.Stdout, currentModel, from, to, step))
+		return
+	}
+
+	if *batch {
+		exitOnError(runBatch(os.Stdin, os.Stdout, outputUnit))
+		return
+	}
+
+	if *exact { //the exact mode has its own pipeline as it doesn't use floats at all
+		table, ok := currentModel.(ruleTable)
+		if !ok {
+			exitOnError(fmt.Errorf("-exact only works with rule tables"))
+		}
+		weights, err := getAndParseExactInput()
+		exitOnError(err)
+		exitOnError(table.coversExact(weights))
+		fmt.Println(new(big.Rat).Quo(table.totalFuelRequiredExact(weights), outputUnit.exact).FloatString(*precision))
+		return
+	}
+
+	weights, err := getAndParseInput() //we read from stdin and convert it to a slice of floats each containing
 	// a weight
-	fmt.Println(totalFuelRequired(weights)) //then we compute the mass of fuel which is required and print the result
+	exitOnError(err)
+	exitOnError(covers(currentModel, weights)) //we make sure the model knows the fuel required by every weight
+
+	switch {
+	case *recursive:
+		totalFuel, breakdown, err := totalRecursiveFuelRequired(weights, *density, *maxIterations)
+		exitOnError(err)
+		exitOnError(printFixedPoints(os.Stdout, totalFuel, breakdown))
+	case *stats:
+		exitOnError(printStats(os.Stdout, weights, percentiles, *barWidth, outputUnit))
+	case *reportFormat != "":
+		exitOnError(buildReport(weights).inUnit(outputUnit).write(os.Stdout, *reportFormat))
+	case isFlagSet("budget"):
+		selection, err := selectCargo(weights, *budget, *objective, *maxCells)
+		exitOnError(err)
+		exitOnError(selection.write(os.Stdout))
+	case vehicles != nil:
+		plan, err := splitLoad(weights, vehicles)
+		exitOnError(err)
+		exitOnError(plan.write(os.Stdout))
+	default:
+		fmt.Println(totalFuelRequired(weights) / outputUnit.kilograms) //then we compute the mass of fuel which is
+		// required and print the result
+	}
+}
+
+func getAndParseInput() (inputFloat []float64, err error) {
+	inputFloat = []float64{}
+	values, err := getInputValues()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values { //we convert it all to float64 kilograms
+		f, err := parseWeight(value.text)
+		if err != nil { //this should only happen with a badly formatted input
+			return nil, value.errorf(i, "%v", err)
+		}
+		inputFloat = append(inputFloat, f)
+	}
+
+	return inputFloat, nil
 }
 
-func getAndParseInput() (inputFloat []float64) {
+//returns the values of the second line of stdin, without converting them
+func getInputValues() ([]inputValue, error) {
 	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 100), 64000000) //a single line of weights can be very long
 
-	scanner.Scan() //we scan twice to discard the first line, as the range operator will determine
-	scanner.Scan() //the length automatically
+	//we scan twice to discard the first line, as the range operator will determine the length automatically
+	if !scanner.Scan() || !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading the weights: %v", err)
+		}
+		return nil, fmt.Errorf("missing the line of weights")
+	}
 	inputStr := scanner.Text()
 
 	inputStr = strings.Replace(inputStr, "\n", "", -1) //we remove the '\n' at the end of the input
 
-	inputSplit := strings.Split(inputStr, " ") //split each value into substrings
+	return splitValues(inputStr), nil //split each value into substrings
+}
 
-	inputFloat = []float64{}
-	for _, str := range inputSplit { //and convert it all to float64
-		f, err := strconv.ParseFloat(str, 64)
-		if err != nil { //this should only happen with a badly formatted input
-			panic(err)
+//returns whether the flag was given on the command line, for flags which have no meaningful default value
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
-		inputFloat = append(inputFloat, f)
-	}
+	})
+	return set
+}
 
-	return inputFloat
+//prints err and stops the program if it isn't nil
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ex1:", err)
+		os.Exit(1)
+	}
 }
 
 func totalFuelRequired(weights []float64) (totalFuel float64) {
@@ -44,10 +214,12 @@ func totalFuelRequired(weights []float64) (totalFuel float64) {
 	return totalFuel
 }
 
+//returns the fuel required by a single weight according to currentModel, which is a rule table or a curve
 func fuelRequired(weight float64) float64 {
-	if weight > 90 {
-		return 80
+	fuel, err := currentModel.fuel(weight)
+	if err != nil { //main checks the weights before calling us so this should never happen
+		panic(err)
 	}
-	return 60
+	return fuel
 }
 
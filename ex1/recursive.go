@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+)
+
+//two successive totals closer than this are considered equal
+const fixedPointTolerance = 1e-9
+
+//the fuel we carry weighs something too, so it needs fuel as well: for each weight we look for the fixed point
+//total = fuelRequired(weight + density * total)
+type fixedPoint struct {
+	weight     float64
+	fuel       float64 //fuel required by the weight alone, as computed by fuelRequired
+	total      float64 //fuel required by the weight and by the fuel carried for it
+	iterations int
+	converged  bool
+}
+
+//starts from the fuel required by the weight alone and adds the mass of the fuel to the load until the amount of
+//fuel stops changing or maxIterations is reached
+func recursiveFuelRequired(weight, density float64, maxIterations int) (fixedPoint, error) {
+	//fuelRequired can't fail on the input weights, but the load grows as we add fuel so it could leave the model
+	fuel, err := currentModel.fuel(weight)
+	if err != nil {
+		return fixedPoint{}, err
+	}
+	fp := fixedPoint{weight: weight, fuel: fuel, total: fuel}
+
+	for fp.iterations < maxIterations {
+		next, err := currentModel.fuel(weight + density*fp.total)
+		if err != nil {
+			return fp, fmt.Errorf("while adding the fuel to weight %v: %v", weight, err)
+		}
+		fp.iterations++
+		done := math.Abs(next-fp.total) <= fixedPointTolerance*math.Max(1, math.Abs(next))
+		fp.total = next
+		if done {
+			fp.converged = true
+			break
+		}
+	}
+	return fp, nil
+}
+
+//this is the recursive version of totalFuelRequired, it also returns the fixed point found for each weight
+func totalRecursiveFuelRequired(weights []float64, density float64, maxIterations int) (
+	totalFuel float64, breakdown []fixedPoint, err error) {
+	breakdown = make([]fixedPoint, 0, len(weights))
+	for _, w := range weights {
+		fp, err := recursiveFuelRequired(w, density, maxIterations)
+		if err != nil {
+			return 0, nil, err
+		}
+		breakdown = append(breakdown, fp)
+		totalFuel += fp.total
+	}
+	return totalFuel, breakdown, nil
+}
+
+//prints one line per weight followed by the total, weights which didn't converge are marked as such
+func printFixedPoints(out io.Writer, totalFuel float64, breakdown []fixedPoint) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "weight\tfuel alone\tfuel with fuel\titerations")
+	for _, fp := range breakdown {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%d", fp.weight, fp.fuel, fp.total, fp.iterations)
+		if !fp.converged {
+			fmt.Fprint(tw, "\tdid not converge")
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, "total", totalFuel)
+	return err
+}
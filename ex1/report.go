@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+//the report lists every weight with the band it fell into and the fuel it costs, followed by the fuel used by each
+//band and the grand total
+type fuelReport struct {
+	Rows      []reportRow    `json:"rows"`
+	Subtotals []bandSubtotal `json:"subtotals"`
+	Total     float64        `json:"total"`
+}
+
+type reportRow struct {
+	Index  int     `json:"index"`
+	Weight float64 `json:"weight"`
+	Band   string  `json:"band"`
+	Fuel   float64 `json:"fuel"`
+}
+
+type bandSubtotal struct {
+	Band  string  `json:"band"`
+	Count int     `json:"count"`
+	Fuel  float64 `json:"fuel"`
+}
+
+//the weights must already have been checked with covers
+func buildReport(weights []float64) fuelReport {
+	report := fuelReport{
+		Rows:      make([]reportRow, 0, len(weights)),
+		Subtotals: make([]bandSubtotal, 0),
+		Total:     totalFuelRequired(weights),
+	}
+
+	names := currentModel.bandNames()
+	subtotals := make([]bandSubtotal, len(names)) //one per band, in the order of the model
+	for i, w := range weights {
+		band, _ := currentModel.band(w)
+		fuel := fuelRequired(w)
+		report.Rows = append(report.Rows, reportRow{Index: i, Weight: w, Band: names[band], Fuel: fuel})
+		subtotals[band].Count++
+		subtotals[band].Fuel += fuel
+	}
+	for band, subtotal := range subtotals { //we only keep the bands which were used
+		if subtotal.Count > 0 {
+			subtotal.Band = names[band]
+			report.Subtotals = append(report.Subtotals, subtotal)
+		}
+	}
+	return report
+}
+
+//returns a copy of the report with every mass converted from kilograms to unit
+func (r fuelReport) inUnit(unit weightUnit) fuelReport {
+	converted := fuelReport{
+		Rows:      make([]reportRow, len(r.Rows)),
+		Subtotals: make([]bandSubtotal, len(r.Subtotals)),
+		Total:     r.Total / unit.kilograms,
+	}
+	for i, row := range r.Rows {
+		row.Weight /= unit.kilograms
+		row.Fuel /= unit.kilograms
+		converted.Rows[i] = row
+	}
+	for i, subtotal := range r.Subtotals {
+		subtotal.Fuel /= unit.kilograms
+		converted.Subtotals[i] = subtotal
+	}
+	return converted
+}
+
+//writes the report in the given format, which is one of json, csv or text
+func (r fuelReport) write(out io.Writer, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case "csv":
+		return r.writeCSV(out)
+	case "text":
+		return r.writeText(out)
+	}
+	return fmt.Errorf("unknown report format %q, expected json, csv or text", format)
+}
+
+//the subtotals and the total are written after the rows, with "subtotal" or "total" in the index column
+func (r fuelReport) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	w.Write([]string{"index", "weight", "band", "fuel"})
+	for _, row := range r.Rows {
+		w.Write([]string{strconv.Itoa(row.Index), formatFloat(row.Weight), row.Band, formatFloat(row.Fuel)})
+	}
+	for _, subtotal := range r.Subtotals {
+		w.Write([]string{"subtotal", "", subtotal.Band, formatFloat(subtotal.Fuel)})
+	}
+	w.Write([]string{"total", "", "", formatFloat(r.Total)})
+
+	w.Flush()
+	return w.Error()
+}
+
+func (r fuelReport) writeText(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "index\tweight\tband\tfuel\t")
+	for _, row := range r.Rows {
+		fmt.Fprintf(tw, "%d\t%v\t%s\t%v\t\n", row.Index, row.Weight, row.Band, row.Fuel)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "\tweights\tband\tfuel\t")
+	for _, subtotal := range r.Subtotals {
+		fmt.Fprintf(tw, "subtotal\t%d\t%s\t%v\t\n", subtotal.Count, subtotal.Band, subtotal.Fuel)
+	}
+	fmt.Fprintf(tw, "total\t%d\t\t%v\t\n", len(r.Rows), r.Total)
+	return tw.Flush()
+}
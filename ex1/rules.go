@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//a fuelRule is a band of the rule table: every weight between low and high costs fixed + perKg * weight
+//each bound can be inclusive or exclusive, and infinite bounds are allowed to make open-ended bands
+type fuelRule struct {
+	name          string
+	low           float64
+	high          float64
+	lowInclusive  bool
+	highInclusive bool
+	fixed         float64
+	perKg         float64
+
+	//the same values as exact rationals, used by the exact mode. nil bounds are infinite
+	lowExact   *big.Rat
+	highExact  *big.Rat
+	fixedExact *big.Rat
+	perKgExact *big.Rat
+}
+
+//a ruleTable is a list of bands which must not overlap and must not leave holes between each other
+type ruleTable []fuelRule
+
+//this is the rule of the original problem: over 90 costs 80, anything else costs 60
+func defaultRuleTable() ruleTable {
+	return ruleTable{
+		{name: "light", low: math.Inf(-1), high: 90, highInclusive: true, fixed: 60,
+			highExact: big.NewRat(90, 1), fixedExact: big.NewRat(60, 1), perKgExact: new(big.Rat)},
+		{name: "heavy", low: 90, high: math.Inf(1), fixed: 80,
+			lowExact: big.NewRat(90, 1), fixedExact: big.NewRat(80, 1), perKgExact: new(big.Rat)},
+	}
+}
+
+func (r fuelRule) contains(weight float64) bool {
+	aboveLow := weight > r.low || (r.lowInclusive && weight == r.low)
+	belowHigh := weight < r.high || (r.highInclusive && weight == r.high)
+	return aboveLow && belowHigh
+}
+
+func (r fuelRule) cost(weight float64) float64 {
+	return r.fixed + r.perKg*weight
+}
+
+//returns the range of the band in the same notation as the one used in rule files
+func (r fuelRule) rangeString() string {
+	left, right := "(", ")"
+	if r.lowInclusive {
+		left = "["
+	}
+	if r.highInclusive {
+		right = "]"
+	}
+	return left + strconv.FormatFloat(r.low, 'g', -1, 64) + ", " + strconv.FormatFloat(r.high, 'g', -1, 64) + right
+}
+
+//returns the index of the band containing weight
+func (t ruleTable) band(weight float64) (int, error) {
+	for i, r := range t {
+		if r.contains(weight) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("weight %v is not covered by any band of the rule table", weight)
+}
+
+func (t ruleTable) fuel(weight float64) (float64, error) {
+	i, err := t.band(weight)
+	if err != nil {
+		return 0, err
+	}
+	return t[i].cost(weight), nil
+}
+
+func (t ruleTable) bandNames() []string {
+	names := make([]string, len(t))
+	for i, r := range t {
+		names[i] = r.name
+	}
+	return names
+}
+
+//sorts the bands by their lower bound and checks that they are not empty, that they don't overlap and that there
+//is no hole between two consecutive bands
+func (t ruleTable) validate() error {
+	if len(t) == 0 {
+		return fmt.Errorf("the rule table is empty")
+	}
+	names := make(map[string]bool)
+	for _, r := range t {
+		if names[r.name] {
+			return fmt.Errorf("band %q is defined twice", r.name)
+		}
+		names[r.name] = true
+		if math.IsNaN(r.low) || math.IsNaN(r.high) || math.IsNaN(r.fixed) || math.IsNaN(r.perKg) {
+			return fmt.Errorf("band %q contains NaN", r.name)
+		}
+		c := compareBounds(r.low, r.lowExact, r.high, r.highExact)
+		if c > 0 || (c == 0 && !(r.lowInclusive && r.highInclusive)) {
+			return fmt.Errorf("band %q has an empty range %s", r.name, r.rangeString())
+		}
+	}
+
+	sort.SliceStable(t, func(i, j int) bool {
+		if c := compareBounds(t[i].low, t[i].lowExact, t[j].low, t[j].lowExact); c != 0 {
+			return c < 0
+		}
+		return t[i].lowInclusive && !t[j].lowInclusive //[a, ...) starts before (a, ...)
+	})
+
+	for i := 1; i < len(t); i++ {
+		prev, next := t[i-1], t[i]
+		c := compareBounds(prev.high, prev.highExact, next.low, next.lowExact)
+		switch {
+		case c > 0, c == 0 && prev.highInclusive && next.lowInclusive:
+			return fmt.Errorf("bands %q %s and %q %s overlap",
+				prev.name, prev.rangeString(), next.name, next.rangeString())
+		case c < 0, c == 0 && !prev.highInclusive && !next.lowInclusive:
+			return fmt.Errorf("there is no band between %q %s and %q %s",
+				prev.name, prev.rangeString(), next.name, next.rangeString())
+		}
+	}
+	return nil
+}
+
+//reads a rule table from a file, each non empty line which isn't a comment describes a band:
+//	name range fixed amount
+//	name range perkg rate [base]
+//where range is written like (-inf, 90] or [90, +inf), square brackets meaning that the bound is included
+//everything following a '#' is a comment
+func loadRuleTable(path string) (ruleTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := ruleTable{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		table = append(table, rule)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = table.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return table, nil
+}
+
+func parseRule(line string) (rule fuelRule, err error) {
+	line = strings.TrimSpace(line)
+	left := strings.IndexAny(line, "[(")
+	right := strings.IndexAny(line, "])")
+	if left <= 0 || right < left {
+		return rule, fmt.Errorf("expected a band name followed by a range such as [0, 90)")
+	}
+
+	rule.name = strings.TrimSpace(line[:left])
+	if strings.ContainsAny(rule.name, " \t") {
+		return rule, fmt.Errorf("band name %q contains spaces", rule.name)
+	}
+	rule.lowInclusive = line[left] == '['
+	rule.highInclusive = line[right] == ']'
+
+	bounds := strings.Split(line[left+1:right], ",")
+	if len(bounds) != 2 {
+		return rule, fmt.Errorf("range %q must contain exactly two bounds", line[left:right+1])
+	}
+	if rule.low, rule.lowExact, err = parseBound(bounds[0]); err != nil {
+		return rule, err
+	}
+	if rule.high, rule.highExact, err = parseBound(bounds[1]); err != nil {
+		return rule, err
+	}
+	if (math.IsInf(rule.low, 0) && rule.lowInclusive) || (math.IsInf(rule.high, 0) && rule.highInclusive) {
+		return rule, fmt.Errorf("infinite bounds can't be included")
+	}
+
+	cost := strings.Fields(line[right+1:])
+	if len(cost) == 0 {
+		return rule, fmt.Errorf("missing cost after the range")
+	}
+	values := make([]float64, len(cost)-1)
+	exactValues := make([]*big.Rat, len(cost)-1)
+	for i, str := range cost[1:] {
+		if values[i], exactValues[i], err = parseNumber(str); err != nil {
+			return rule, fmt.Errorf("invalid cost %q", str)
+		}
+	}
+	rule.fixedExact, rule.perKgExact = new(big.Rat), new(big.Rat)
+	switch {
+	case cost[0] == "fixed" && len(values) == 1:
+		rule.fixed, rule.fixedExact = values[0], exactValues[0]
+	case cost[0] == "perkg" && len(values) == 1:
+		rule.perKg, rule.perKgExact = values[0], exactValues[0]
+	case cost[0] == "perkg" && len(values) == 2:
+		rule.perKg, rule.perKgExact = values[0], exactValues[0]
+		rule.fixed, rule.fixedExact = values[1], exactValues[1]
+	default:
+		return rule, fmt.Errorf("cost must be either \"fixed amount\" or \"perkg rate [base]\"")
+	}
+	return rule, nil
+}
+
+//returns the bound both as a float and as an exact rational, which is nil for infinite bounds
+func parseBound(str string) (float64, *big.Rat, error) {
+	str = strings.TrimSpace(str)
+	switch str {
+	case "-inf":
+		return math.Inf(-1), nil, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil, nil
+	}
+	f, exact, err := parseNumber(str)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid bound %q", str)
+	}
+	return f, exact, nil
+}
+
+//parses a finite number, the rational keeps every digit written in str even those a float64 can't hold
+func parseNumber(str string) (float64, *big.Rat, error) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, nil, fmt.Errorf("%q is not a finite number", str)
+	}
+	exact, ok := new(big.Rat).SetString(str)
+	if !ok { //SetString doesn't know every syntax ParseFloat accepts, the float is exact anyway in these cases
+		exact = new(big.Rat).SetFloat64(f)
+	}
+	return f, exact, nil
+}
+
+//compares two bounds of the table, exactly when both of them are finite
+func compareBounds(a float64, aExact *big.Rat, b float64, bExact *big.Rat) int {
+	if aExact != nil && bExact != nil {
+		return aExact.Cmp(bExact)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
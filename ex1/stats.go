@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//the statistics mode describes the weights of a manifest before we trust its total: a few descriptive statistics,
+//then a histogram of the weights in each band of the model
+
+type weightStats struct {
+	count       int
+	min         float64
+	max         float64
+	mean        float64
+	median      float64
+	percentiles []float64 //the requested percentiles, in the same order
+}
+
+//parses a list of percentiles such as "10,25,75,90"
+func parsePercentiles(spec string) ([]float64, error) {
+	percentiles := make([]float64, 0)
+	if strings.TrimSpace(spec) == "" {
+		return percentiles, nil
+	}
+	for _, str := range strings.Split(spec, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q, it must be between 0 and 100", str)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+func computeStats(weights []float64, percentiles []float64) weightStats {
+	stats := weightStats{count: len(weights), percentiles: make([]float64, len(percentiles))}
+	if len(weights) == 0 {
+		return stats
+	}
+
+	sorted := append([]float64(nil), weights...)
+	sort.Float64s(sorted)
+	stats.min, stats.max = sorted[0], sorted[len(sorted)-1]
+	sum := 0.
+	for _, w := range sorted {
+		sum += w
+	}
+	stats.mean = sum / float64(len(sorted))
+	stats.median = percentile(sorted, 50)
+	for i, p := range percentiles {
+		stats.percentiles[i] = percentile(sorted, p)
+	}
+	return stats
+}
+
+//interpolates linearly between the two closest ranks, sorted must not be empty
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	fraction := rank - float64(lower)
+	return sorted[lower] + fraction*(sorted[lower+1]-sorted[lower])
+}
+
+//prints the statistics of the weights, the histogram of the bands and the total fuel. every band of the model has a
+//line, even the empty ones, so that gaps in a manifest are visible
+func printStats(out io.Writer, weights []float64, percentiles []float64, barWidth int, unit weightUnit) error {
+	stats := computeStats(weights, percentiles)
+	inUnit := func(kg float64) float64 {
+		return kg / unit.kilograms
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "count\t%d\n", stats.count)
+	if stats.count > 0 {
+		fmt.Fprintf(tw, "min\t%v\n", inUnit(stats.min))
+		fmt.Fprintf(tw, "max\t%v\n", inUnit(stats.max))
+		fmt.Fprintf(tw, "mean\t%v\n", inUnit(stats.mean))
+		fmt.Fprintf(tw, "median\t%v\n", inUnit(stats.median))
+		for i, p := range percentiles {
+			fmt.Fprintf(tw, "p%v\t%v\n", p, inUnit(stats.percentiles[i]))
+		}
+	}
+	fmt.Fprintln(tw)
+
+	names := currentModel.bandNames()
+	counts := make([]int, len(names))
+	fuels := make([]float64, len(names))
+	highest := 0
+	for _, w := range weights {
+		band, _ := currentModel.band(w)
+		counts[band]++
+		fuels[band] += fuelRequired(w)
+		if counts[band] > highest {
+			highest = counts[band]
+		}
+	}
+	fmt.Fprintln(tw, "band\tweights\tfuel\thistogram")
+	for band, name := range names {
+		bar := 0
+		if highest > 0 {
+			bar = int(math.Round(float64(counts[band]) / float64(highest) * float64(barWidth)))
+		}
+		if bar == 0 && counts[band] > 0 { //a band with a few weights must still be visible
+			bar = 1
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t|%s\n", name, counts[band], inUnit(fuels[band]), strings.Repeat("#", bar))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(out, "\ntotal", inUnit(totalFuelRequired(weights)))
+	return err
+}
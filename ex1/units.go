@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//weights can be written with a unit suffix such as 1.2t, 85kg or 200lb, they are all converted to kilograms before
+//being classified. a value without any suffix is already in kilograms
+type weightUnit struct {
+	name      string
+	kilograms float64  //mass of one unit
+	exact     *big.Rat //the same, for the exact mode
+}
+
+var weightUnits = map[string]weightUnit{
+	"kg": newWeightUnit("kg", "1"),
+	"t":  newWeightUnit("t", "1000"),
+	"lb": newWeightUnit("lb", "0.45359237"), //this is the exact definition of the international pound
+}
+
+func newWeightUnit(name, kilograms string) weightUnit {
+	f, exact, err := parseNumber(kilograms)
+	if err != nil {
+		panic(err)
+	}
+	return weightUnit{name: name, kilograms: f, exact: exact}
+}
+
+func findUnit(name string) (weightUnit, error) {
+	unit, ok := weightUnits[name]
+	if !ok {
+		return unit, fmt.Errorf("unknown unit %q, expected kg, t or lb", name)
+	}
+	return unit, nil
+}
+
+//a value of the input line and the column it starts at, so that errors can tell where the problem is
+type inputValue struct {
+	text   string
+	column int
+}
+
+func (v inputValue) errorf(index int, format string, a ...interface{}) error {
+	return fmt.Errorf("value %d (%q at column %d): %s", index+1, v.text, v.column, fmt.Sprintf(format, a...))
+}
+
+//splits the line on spaces and remembers where each value starts, columns are counted from 1
+func splitValues(line string) []inputValue {
+	values := make([]inputValue, 0)
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		end := i
+		for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+			end++
+		}
+		values = append(values, inputValue{text: line[i:end], column: i + 1})
+		i = end
+	}
+	return values
+}
+
+//separates the number from its unit: the unit is made of the letters at the end of the value, except for an
+//exponent such as the one in 1e3 which is followed by digits and thus part of the number
+func splitUnit(str string) (number string, unit weightUnit, err error) {
+	end := len(str)
+	for end > 0 && isLetter(str[end-1]) {
+		end--
+	}
+	if end == len(str) {
+		return str, weightUnits["kg"], nil
+	}
+	if end == 0 {
+		return "", unit, fmt.Errorf("missing number before the unit")
+	}
+	unit, err = findUnit(strings.ToLower(str[end:]))
+	return str[:end], unit, err
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+//returns the weight in kilograms
+func parseWeight(str string) (float64, error) {
+	number, unit, err := splitUnit(str)
+	if err != nil {
+		return 0, err
+	}
+	f, _, err := parseNumber(number)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+	return f * unit.kilograms, nil
+}
+
+func parseWeightExact(str string) (*big.Rat, error) {
+	number, unit, err := splitUnit(str)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := new(big.Rat).SetString(number)
+	if !ok || strings.Contains(number, "/") { //SetString also accepts fractions, which the normal mode doesn't
+		return nil, fmt.Errorf("invalid number %q", number)
+	}
+	return r.Mul(r, unit.exact), nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//when the cargo is split between several vehicles, each trip of a vehicle can't carry more than its capacity and
+//costs a fixed overhead on top of the fuel required by the weights it carries. the fuel of the weights doesn't depend
+//on the vehicle, so what we minimise is the overhead of the trips, which makes this a bin packing problem with
+//different kinds of bins: it is NP-hard, so we use the classic decreasing first fit and best fit heuristics
+type vehicle struct {
+	name     string
+	capacity float64
+	overhead float64
+	maxTrips int //0 means unlimited
+}
+
+type trip struct {
+	vehicle int
+	items   []int
+	load    float64
+}
+
+type loadPlan struct {
+	vehicles []vehicle
+	trips    []trip
+	itemFuel []float64 //fuelRequired of each weight
+	method   string
+}
+
+//parses a list such as "van:500:20,truck:2000:50:3", each vehicle being name:capacity:overhead[:maximum trips]
+func parseVehicles(spec string) ([]vehicle, error) {
+	vehicles := make([]vehicle, 0)
+	for _, str := range strings.Split(spec, ",") {
+		fields := strings.Split(strings.TrimSpace(str), ":")
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, fmt.Errorf("vehicle %q must be written name:capacity:overhead[:maximum trips]", str)
+		}
+		v := vehicle{name: fields[0]}
+		var err error
+		if v.capacity, err = strconv.ParseFloat(fields[1], 64); err != nil || !(v.capacity > 0) {
+			return nil, fmt.Errorf("vehicle %q: invalid capacity %q", v.name, fields[1])
+		}
+		if v.overhead, err = strconv.ParseFloat(fields[2], 64); err != nil || !(v.overhead >= 0) {
+			return nil, fmt.Errorf("vehicle %q: invalid overhead %q", v.name, fields[2])
+		}
+		if len(fields) == 4 {
+			if v.maxTrips, err = strconv.Atoi(fields[3]); err != nil || v.maxTrips < 1 {
+				return nil, fmt.Errorf("vehicle %q: invalid maximum number of trips %q", v.name, fields[3])
+			}
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, nil
+}
+
+//assigns every weight to a trip of a vehicle, trying both heuristics and keeping the cheapest plan
+func splitLoad(weights []float64, vehicles []vehicle) (loadPlan, error) {
+	itemFuel := make([]float64, len(weights))
+	for i, w := range weights {
+		if w < 0 {
+			return loadPlan{}, fmt.Errorf("weight %v can't be loaded in a vehicle", w)
+		}
+		itemFuel[i] = fuelRequired(w)
+	}
+
+	order := make([]int, len(weights)) //both heuristics place the heaviest items first
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return weights[order[a]] > weights[order[b]]
+	})
+
+	var best loadPlan
+	for _, bestFit := range []bool{false, true} {
+		plan := loadPlan{vehicles: vehicles, itemFuel: itemFuel, method: "first fit decreasing"}
+		if bestFit {
+			plan.method = "best fit decreasing"
+		}
+		var err error
+		if plan.trips, err = packTrips(weights, order, vehicles, bestFit); err != nil {
+			return loadPlan{}, err
+		}
+		if best.trips == nil || plan.totalFuel() < best.totalFuel() {
+			best = plan
+		}
+	}
+	return best, nil
+}
+
+//puts each item in an open trip where it fits: the first one, or the one it fills the most when bestFit is set. when
+//none of them can take it, a new trip is started with the vehicle which has the cheapest overhead per unit of
+//capacity. at the end each trip is moved to the vehicle with the smallest overhead which can still carry its load
+func packTrips(weights []float64, order []int, vehicles []vehicle, bestFit bool) ([]trip, error) {
+	trips := make([]trip, 0)
+	tripsUsed := make([]int, len(vehicles))
+	available := func(v int) bool {
+		return vehicles[v].maxTrips == 0 || tripsUsed[v] < vehicles[v].maxTrips
+	}
+
+	for _, item := range order {
+		w := weights[item]
+		chosen := -1
+		for t := range trips {
+			room := vehicles[trips[t].vehicle].capacity - trips[t].load
+			if room < w {
+				continue
+			}
+			if chosen == -1 || (bestFit && room < vehicles[trips[chosen].vehicle].capacity-trips[chosen].load) {
+				chosen = t
+			}
+			if !bestFit {
+				break
+			}
+		}
+
+		if chosen == -1 { //we need a new trip
+			newVehicle := -1
+			for v := range vehicles {
+				if vehicles[v].capacity < w || !available(v) {
+					continue
+				}
+				if newVehicle == -1 || costPerCapacity(vehicles[v]) < costPerCapacity(vehicles[newVehicle]) ||
+					(costPerCapacity(vehicles[v]) == costPerCapacity(vehicles[newVehicle]) &&
+						vehicles[v].capacity > vehicles[newVehicle].capacity) {
+					newVehicle = v
+				}
+			}
+			if newVehicle == -1 {
+				return nil, fmt.Errorf("no vehicle left can carry weight %v (item %d)", w, item)
+			}
+			tripsUsed[newVehicle]++
+			trips = append(trips, trip{vehicle: newVehicle, items: make([]int, 0)})
+			chosen = len(trips) - 1
+		}
+
+		trips[chosen].items = append(trips[chosen].items, item)
+		trips[chosen].load += w
+	}
+
+	for t := range trips { //a trip which ended up half empty may fit in a cheaper vehicle
+		current := trips[t].vehicle
+		tripsUsed[current]--
+		for v := range vehicles {
+			if vehicles[v].capacity >= trips[t].load && available(v) &&
+				vehicles[v].overhead < vehicles[trips[t].vehicle].overhead {
+				trips[t].vehicle = v
+			}
+		}
+		tripsUsed[trips[t].vehicle]++
+	}
+
+	sort.SliceStable(trips, func(a, b int) bool { //we group the trips by vehicle for the report
+		return trips[a].vehicle < trips[b].vehicle
+	})
+	for t := range trips {
+		sort.Ints(trips[t].items)
+	}
+	return trips, nil
+}
+
+func costPerCapacity(v vehicle) float64 {
+	return v.overhead / v.capacity
+}
+
+func (p loadPlan) tripFuel(t trip) float64 {
+	fuel := p.vehicles[t.vehicle].overhead
+	for _, item := range t.items {
+		fuel += p.itemFuel[item]
+	}
+	return fuel
+}
+
+func (p loadPlan) totalFuel() float64 {
+	total := 0.
+	for _, t := range p.trips {
+		total += p.tripFuel(t)
+	}
+	return total
+}
+
+//prints every trip with its items, then the fuel used by each vehicle and the total
+func (p loadPlan) write(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "vehicle\ttrip\tload\tfuel\titems")
+	tripNumber := make([]int, len(p.vehicles))
+	vehicleFuel := make([]float64, len(p.vehicles))
+	for _, t := range p.trips {
+		tripNumber[t.vehicle]++
+		vehicleFuel[t.vehicle] += p.tripFuel(t)
+		items := make([]string, len(t.items))
+		for i, item := range t.items {
+			items[i] = strconv.Itoa(item)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%s\n",
+			p.vehicles[t.vehicle].name, tripNumber[t.vehicle], t.load, p.tripFuel(t), strings.Join(items, " "))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "vehicle\ttrips\tfuel")
+	for v, vehicle := range p.vehicles {
+		fmt.Fprintf(tw, "%s\t%d\t%v\n", vehicle.name, tripNumber[v], vehicleFuel[v])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\ntotal %v\nmethod %s\n", p.totalFuel(), p.method)
+	return err
+}
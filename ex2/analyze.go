@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//the analyzer tells how much the result of a pipeline depends on the order of its filters. it runs the filters on
+//random messages made of the runes they care about, and checks which pairs of filters give the same result in both
+//orders and which filters give the same result when they are applied twice. two filters next to each other which
+//don't commute make the order fragile: swapping them changes the cleaned messages. as the messages are random, a
+//pair which commutes on all of them could still differ on another one
+
+//the runes which are always in the random messages, besides those of the parameters of the filters
+const analyzeAlphabet = "ab1 "
+
+//the longest random message
+const analyzeLength = 12
+
+//the first message on which two ways of applying filters differ, with what each of them gives
+type counterexample struct {
+	message string
+	first   string
+	second  string
+}
+
+//describes the difference, firstWay and secondWay telling how the message was cleaned
+func (c *counterexample) describe(firstWay, secondWay string) string {
+	return fmt.Sprintf("%s gives %s %s but %s %s", strconv.Quote(c.message), strconv.Quote(c.first), firstWay,
+		strconv.Quote(c.second), secondWay)
+}
+
+type pipelineAnalysis struct {
+	filters    []filter.Filter
+	idempotent []*counterexample   //nil when the filter is idempotent
+	commute    [][]*counterexample //for i < j, nil when the filters i and j commute
+}
+
+//returns the runes the random messages are made of: those of the parameters of the filters, such as delimiters, and
+//a few others
+func analyzeRunes(filters []filter.Filter) []rune {
+	seen := make(map[rune]bool)
+	runes := make([]rune, 0)
+	add := func(s string) {
+		for _, r := range s {
+			if !seen[r] {
+				seen[r] = true
+				runes = append(runes, r)
+			}
+		}
+	}
+	add(analyzeAlphabet)
+	for _, f := range filters {
+		for _, p := range f.Params() {
+			if !p.Flag {
+				add(p.Value)
+			}
+		}
+	}
+	return runes
+}
+
+//applies the filters in order, an error being a result as well
+func applyFilters(message string, filters ...filter.Filter) string {
+	cleaned, err := removeInterferences(message, filters...)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return cleaned
+}
+
+//compares two ways of cleaning on every message, and keeps the shortest message on which they differ
+func compareOn(messages []string, first, second func(message string) string) *counterexample {
+	var found *counterexample
+	for _, message := range messages {
+		if found != nil && len(message) >= len(found.message) {
+			continue
+		}
+		a, b := first(message), second(message)
+		if a != b {
+			found = &counterexample{message: message, first: a, second: b}
+		}
+	}
+	return found
+}
+
+func analyzePipeline(filters []filter.Filter, samples int, seed int64) pipelineAnalysis {
+	random := rand.New(rand.NewSource(seed))
+	runes := analyzeRunes(filters)
+	messages := make([]string, samples)
+	for i := range messages {
+		message := make([]rune, random.Intn(analyzeLength+1))
+		for j := range message {
+			message[j] = runes[random.Intn(len(runes))]
+		}
+		messages[i] = string(message)
+	}
+
+	analysis := pipelineAnalysis{
+		filters:    filters,
+		idempotent: make([]*counterexample, len(filters)),
+		commute:    make([][]*counterexample, len(filters)),
+	}
+	for i, f := range filters {
+		analysis.idempotent[i] = compareOn(messages, func(m string) string {
+			return applyFilters(m, f)
+		}, func(m string) string {
+			return applyFilters(m, f, f)
+		})
+
+		analysis.commute[i] = make([]*counterexample, len(filters))
+		for j := i + 1; j < len(filters); j++ {
+			g := filters[j]
+			analysis.commute[i][j] = compareOn(messages, func(m string) string {
+				return applyFilters(m, f, g)
+			}, func(m string) string {
+				return applyFilters(m, g, f)
+			})
+		}
+	}
+	return analysis
+}
+
+//prints whether each filter is idempotent and each pair commutes, then warns about the fragile places
+func (a pipelineAnalysis) write(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "filter\tidempotent")
+	for i, f := range a.filters {
+		result := "yes"
+		if c := a.idempotent[i]; c != nil {
+			result = "no, " + c.describe("once", "twice")
+		}
+		fmt.Fprintf(tw, "%d %s\t%s\n", i+1, filter.Spec(f), result)
+	}
+	if len(a.filters) > 1 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "pair\tcommute")
+		for i := range a.filters {
+			for j := i + 1; j < len(a.filters); j++ {
+				result := "yes"
+				if c := a.commute[i][j]; c != nil {
+					result = "no, " + c.describe("in this order", "the other way")
+				}
+				fmt.Fprintf(tw, "%d %d\t%s\n", i+1, j+1, result)
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	warnings := make([]string, 0)
+	for i := 0; i+1 < len(a.filters); i++ {
+		if a.commute[i][i+1] != nil {
+			warnings = append(warnings, fmt.Sprintf("filters %d and %d don't commute, swapping them changes the "+
+				"cleaned messages", i+1, i+2))
+		}
+	}
+	for i := range a.filters {
+		if a.idempotent[i] != nil {
+			warnings = append(warnings, fmt.Sprintf("filter %d isn't idempotent, what it leaves can look like "+
+				"interference again", i+1))
+		}
+	}
+	if len(warnings) > 0 {
+		_, err := fmt.Fprintln(out, "\nwarning: "+strings.Join(warnings, "\nwarning: "))
+		return err
+	}
+	return nil
+}
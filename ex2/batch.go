@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//in batch mode the input contains many messages, which all go through the same pipeline. they are either one per
+//line, or records as in the original problem: a line with the length of the message in bytes, then the message and
+//a newline. with records, the message is read by its length so it can contain newlines, and a wrong length is an
+//error which stops the batch as every following record would be shifted. as with a single message, the newlines may
+//be \r\n
+const (
+	batchLines   = "lines"
+	batchRecords = "records"
+)
+
+type batchMessage struct {
+	number int //counted from 1
+	text   string
+}
+
+//reads the messages from r and calls handle on each of them in order
+func readMessages(r io.Reader, format string, handle func(m batchMessage) error) error {
+	reader := bufio.NewReader(r)
+	for number := 1; ; number++ {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r") //as lineReader does
+
+		if format == batchLines {
+			if err := handle(batchMessage{number: number, text: line}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		length, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || length < 0 {
+			return fmt.Errorf("record %d: expected the length of the message, got %q", number, line)
+		}
+		text := make([]byte, length)
+		if _, err := io.ReadFull(reader, text); err != nil {
+			return fmt.Errorf("record %d: the input ends before the %d bytes of the message", number, length)
+		}
+		c, err := reader.ReadByte()
+		if err == nil && c == '\r' { //the \r of a \r\n, or the last byte of the input
+			c, err = reader.ReadByte()
+		}
+		if err == nil && c != '\n' {
+			return fmt.Errorf("record %d: the message is longer than %d bytes", number, length)
+		}
+		if err := handle(batchMessage{number: number, text: string(text)}); err != nil {
+			return err
+		}
+	}
+}
+
+type batchResult struct {
+	cleaned string
+	err     error
+}
+
+type batchJob struct {
+	message batchMessage
+	result  chan batchResult
+}
+
+//cleans the messages on workers goroutines and writes them to out in the order of the input and in the same format. a
+//message rejected by the pipeline is reported on stderr and gives an empty message, so that the output still matches
+//the input. at most a few messages per worker wait to be written, so the memory doesn't depend on the size of the batch
+func runBatch(in io.Reader, out io.Writer, format string, workers int, filters []filter.Filter) (total, rejected int,
+	err error) {
+	jobs := make(chan batchJob)
+	order := make(chan chan batchResult, 4*workers) //the results in the order of the input
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				cleaned, err := removeInterferences(job.message.text, filters...)
+				job.result <- batchResult{cleaned: cleaned, err: err}
+			}
+		}()
+	}
+
+	var readErr error
+	go func() {
+		readErr = readMessages(in, format, func(m batchMessage) error {
+			result := make(chan batchResult, 1) //so that the workers never wait for the writer
+			order <- result
+			jobs <- batchJob{message: m, result: result}
+			return nil
+		})
+		close(jobs)
+		close(order)
+	}()
+
+	writer := bufio.NewWriter(out)
+	for result := range order {
+		total++
+		r := <-result
+		if r.err != nil {
+			rejected++
+			fmt.Fprintf(os.Stderr, "ex2: message %d: %v\n", total, r.err)
+		}
+		if format == batchRecords {
+			fmt.Fprintln(writer, len(r.cleaned))
+		}
+		if _, err := fmt.Fprintln(writer, r.cleaned); err != nil {
+			return total, rejected, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return total, rejected, err
+	}
+	return total, rejected, readErr //order is closed after readErr is set
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//what the between filter does with a span which is opened but never closed, and with a close delimiter which
+//doesn't close anything
+const (
+	unbalancedDrop  = "drop"  //the span goes to the end of the message, a stray close delimiter is removed
+	unbalancedKeep  = "keep"  //the unmatched delimiter is treated as normal text and kept
+	unbalancedError = "error" //the message is rejected
+)
+
+type betweenOptions struct {
+	open       string
+	close      string
+	nested     bool   //spans can contain other spans, which requires different delimiters
+	escape     string //a delimiter following it is normal text, empty if there is no escape sequence
+	unbalanced string
+	grapheme   bool //delimiters only match whole grapheme clusters
+}
+
+//parses the options following the two delimiters of between: nested, escape=X, unbalanced=drop|keep|error and
+//grapheme
+func parseBetweenOptions(open, close string, args []filter.Arg) (betweenOptions, error) {
+	options := betweenOptions{open: open, close: close, unbalanced: unbalancedDrop}
+	if open == "" || close == "" {
+		return options, fmt.Errorf("the delimiters of between can't be empty")
+	}
+	for _, arg := range args {
+		key, value := arg.Text, ""
+		if i := strings.IndexByte(arg.Text, '='); i >= 0 && !arg.Quoted {
+			key, value = arg.Text[:i], arg.Text[i+1:]
+		}
+		switch {
+		case key == "nested" && value == "" && !arg.Quoted:
+			options.nested = true
+		case key == "grapheme" && value == "" && !arg.Quoted:
+			options.grapheme = true
+		case key == "escape" && value != "":
+			options.escape = value
+		case key == "unbalanced" && (value == unbalancedDrop || value == unbalancedKeep || value == unbalancedError):
+			options.unbalanced = value
+		default:
+			return options, fmt.Errorf("unknown option %q for between, expected nested, escape=X, "+
+				"unbalanced=drop|keep|error or grapheme", arg.Text)
+		}
+	}
+	if options.nested && open == close {
+		return options, fmt.Errorf("between can't nest spans whose delimiters are the same")
+	}
+	if options.escape == open || options.escape == close {
+		return options, fmt.Errorf("the escape sequence of between can't be one of its delimiters")
+	}
+	return options, nil
+}
+
+func init() {
+	filter.Register(filter.Kind{
+		Name:        "between",
+		Usage:       `"open" "close" [nested] [escape="X"] [unbalanced=drop|keep|error] [grapheme]`,
+		Description: "removes every part of the message which starts with open and ends with close, delimiters included",
+		Parse: func(args []filter.Arg) (filter.Filter, error) {
+			if len(args) < 2 { //the delimiters can be followed by options
+				return nil, fmt.Errorf("wrong number of arguments for between, expected at least 2")
+			}
+			options, err := parseBetweenOptions(args[0].Text, args[1].Text, args[2:])
+			if err != nil {
+				return nil, err
+			}
+			return betweenFilter{options}, nil
+		},
+		Candidates: func(examples []filter.Example) []filter.Filter { //every pair of runes which disappear, nested or not
+			runes := removedRunes(examples, learnedRunes)
+			candidates := make([]filter.Filter, 0, 2*len(runes)*len(runes))
+			for _, left := range runes {
+				for _, right := range runes {
+					options := betweenOptions{open: string(left), close: string(right), unbalanced: unbalancedDrop}
+					candidates = append(candidates, betweenFilter{options})
+					if left != right {
+						options.nested = true
+						candidates = append(candidates, betweenFilter{options})
+					}
+				}
+			}
+			return candidates
+		},
+	})
+}
+
+type betweenFilter struct {
+	options betweenOptions
+}
+
+func (f betweenFilter) Name() string {
+	return "between"
+}
+
+func (f betweenFilter) Description() string {
+	return describe("between")
+}
+
+func (f betweenFilter) Params() []filter.Param {
+	params := []filter.Param{
+		{Name: "open", Value: f.options.open, Positional: true},
+		{Name: "close", Value: f.options.close, Positional: true},
+	}
+	if f.options.nested {
+		params = append(params, filter.Param{Name: "nested", Flag: true})
+	}
+	if f.options.escape != "" {
+		params = append(params, filter.Param{Name: "escape", Value: f.options.escape})
+	}
+	if f.options.unbalanced != unbalancedDrop {
+		params = append(params, filter.Param{Name: "unbalanced", Value: f.options.unbalanced})
+	}
+	if f.options.grapheme {
+		params = append(params, filter.Param{Name: "grapheme", Flag: true})
+	}
+	return params
+}
+
+func (f betweenFilter) NewTransformer() filter.Transformer {
+	return &betweenTransformer{
+		open:    []rune(f.options.open),
+		close:   []rune(f.options.close),
+		escape:  []rune(f.options.escape),
+		options: f.options,
+		last:    -1,
+	}
+}
+
+//the between filter is a state machine: as delimiters can be made of several runes, the runes we received but
+//couldn't classify yet wait in pending. when spans which are never closed must be kept, we can't know whether a
+//span is interference before it is closed, so its runes wait in span, which is the only thing growing with the
+//length of the message
+type betweenTransformer struct {
+	open    []rune
+	close   []rune
+	escape  []rune
+	options betweenOptions
+
+	pending []rune
+	depth   int    //number of spans we are in
+	span    []rune //runes of the outermost span, only used with unbalanced=keep
+	offset  int    //number of runes consumed so far, for error messages
+	last    rune   //the last rune consumed, -1 at the beginning
+	opened  int    //offset of the outermost span
+}
+
+func (t *betweenTransformer) Feed(r rune, out filter.Emitter) error {
+	t.pending = append(t.pending, r)
+	return t.process(out, false)
+}
+
+func (t *betweenTransformer) End(out filter.Emitter) error {
+	if err := t.process(out, true); err != nil {
+		return err
+	}
+	for t.depth > 0 {
+		switch t.options.unbalanced {
+		case unbalancedError:
+			return fmt.Errorf("%q at rune %d is never closed", t.options.open, t.opened)
+		case unbalancedKeep: //the delimiter was just text, we read again what follows it
+			reread := t.span[len(t.open):]
+			t.span, t.depth = nil, 0
+			t.last = t.open[len(t.open)-1]
+			for _, r := range t.open {
+				if err := out.Keep(r); err != nil {
+					return err
+				}
+			}
+			t.pending = append(reread, t.pending...)
+			t.offset -= len(reread)
+			if err := t.process(out, true); err != nil {
+				return err
+			}
+		default: //the span was dropped as we went
+			t.depth = 0
+		}
+	}
+	return nil
+}
+
+//consumes as much of pending as possible. unless this is the end of the message, we stop when what is pending
+//could still become a delimiter
+func (t *betweenTransformer) process(out filter.Emitter, final bool) error {
+	for len(t.pending) > 0 {
+		match, wait := t.matchAny(t.pending, final)
+		if wait {
+			return nil
+		}
+
+		var err error
+		switch {
+		case match == nil: //a normal rune
+			err = t.consume(out, 1, t.depth == 0)
+		case sameRunes(match, t.escape):
+			protected, wait := t.matchProtected(t.pending[len(t.escape):], final)
+			if wait {
+				return nil
+			}
+			if protected == nil { //the escape sequence protects nothing so it is text
+				err = t.consume(out, len(t.escape), t.depth == 0)
+			} else if err = t.consume(out, len(t.escape), false); err == nil {
+				err = t.consume(out, len(protected), t.depth == 0)
+			}
+		case t.depth > 0 && sameRunes(match, t.close):
+			err = t.consume(out, len(t.close), false)
+			t.depth--
+			if err == nil && t.depth == 0 && t.span != nil { //the span is complete so it was interference
+				err = t.flushSpan(out)
+			}
+		case sameRunes(match, t.open):
+			if t.depth == 0 {
+				t.opened = t.offset
+				if t.options.unbalanced == unbalancedKeep {
+					t.span = make([]rune, 0)
+				}
+			}
+			t.depth++
+			err = t.consume(out, len(t.open), false)
+		default: //a close delimiter which doesn't close anything
+			if t.options.unbalanced == unbalancedError {
+				return fmt.Errorf("%q at rune %d doesn't close anything", t.options.close, t.offset)
+			}
+			err = t.consume(out, len(t.close), t.options.unbalanced == unbalancedKeep)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//returns the delimiter or escape sequence starting at the beginning of runes, or nil. the candidates are tried in
+//order of priority, and we must wait when runes is the beginning of one of them as we need more runes to know
+func (t *betweenTransformer) matchAny(runes []rune, final bool) (match []rune, wait bool) {
+	candidates := [][]rune{t.escape, t.open, t.close} //outside of spans, a stray close comes last
+	if t.depth > 0 {
+		candidates = [][]rune{t.escape, t.close} //we check close first for "*" "*"
+		if t.options.nested {
+			candidates = append(candidates, t.open)
+		}
+	}
+	match, wait = matchFirst(runes, candidates, final)
+	return t.wholeClusters(t.last, runes, match, wait, final)
+}
+
+//returns what the escape sequence at the beginning of runes protects: the escape sequence itself or a delimiter
+func (t *betweenTransformer) matchProtected(runes []rune, final bool) (match []rune, wait bool) {
+	match, wait = matchFirst(runes, [][]rune{t.escape, t.open, t.close}, final)
+	return t.wholeClusters(t.escape[len(t.escape)-1], runes, match, wait, final)
+}
+
+//with the grapheme option, a match which starts or ends in the middle of a grapheme cluster isn't one, such as "*"
+//followed by a combining accent. previous is the rune before runes, and we must wait for the rune after the match
+func (t *betweenTransformer) wholeClusters(previous rune, runes, match []rune, wait, final bool) ([]rune, bool) {
+	if !t.options.grapheme || match == nil || wait {
+		return match, wait
+	}
+	if previous >= 0 && extendsCluster(previous, runes[0]) {
+		return nil, false
+	}
+	if len(runes) == len(match) {
+		return match, !final
+	}
+	if extendsCluster(match[len(match)-1], runes[len(match)]) {
+		return nil, false
+	}
+	return match, false
+}
+
+func matchFirst(runes []rune, candidates [][]rune, final bool) (match []rune, wait bool) {
+	for _, candidate := range candidates {
+		if len(candidate) == 0 {
+			continue
+		}
+		if hasRunePrefix(runes, candidate) {
+			return candidate, false
+		}
+		if !final && len(runes) < len(candidate) && hasRunePrefix(candidate, runes) {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+//removes n runes from pending and emits them, or puts them in the current span when we must wait for its end
+func (t *betweenTransformer) consume(out filter.Emitter, n int, keep bool) error {
+	runes := t.pending[:n]
+	t.offset += n
+	t.last = runes[n-1]
+	if t.span != nil {
+		t.span = append(t.span, runes...)
+		t.pending = t.pending[n:]
+		return nil
+	}
+	for _, r := range runes {
+		var err error
+		if keep {
+			err = out.Keep(r)
+		} else {
+			err = out.Remove(r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.pending = t.pending[n:]
+	return nil
+}
+
+func (t *betweenTransformer) flushSpan(out filter.Emitter) error {
+	span := t.span
+	t.span = nil
+	for _, r := range span {
+		if err := out.Remove(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasRunePrefix(runes, prefix []rune) bool {
+	return len(runes) >= len(prefix) && sameRunes(runes[:len(prefix)], prefix)
+}
+
+func sameRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
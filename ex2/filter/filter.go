@@ -0,0 +1,132 @@
+//Package filter is what the pipelines of ex2 are made of. a filter removes one kind of interference from messages,
+//and registers itself under a name so that pipelines can select it: ex2 registers its own filters, and a filter of
+//another package is registered in the init function of that package, which ex2 then imports for its side effects
+//	import _ "example.com/team/interference"
+package filter
+
+import (
+	"bufio"
+	"strconv"
+	"unicode/utf8"
+)
+
+//a Filter is configured once when the pipeline is loaded. as transformers hold the state of a single message, the
+//filter creates a new one for each message it cleans
+type Filter interface {
+	Name() string
+	Description() string
+	Params() []Param //the parameters it was configured with, in the order of the pipeline
+	NewTransformer() Transformer
+}
+
+//a parameter of a filter: positional parameters are written as strings, the others as name=value, or as their name
+//alone when they are flags
+type Param struct {
+	Name       string
+	Value      string
+	Positional bool
+	Flag       bool
+}
+
+//returns the filter as it would be written in a pipeline, for error messages
+func Spec(f Filter) string {
+	spec := f.Name()
+	for _, p := range f.Params() {
+		switch {
+		case p.Positional:
+			spec += " " + strconv.Quote(p.Value)
+		case p.Flag:
+			spec += " " + p.Name
+		default:
+			spec += " " + p.Name + "=" + strconv.Quote(p.Value)
+		}
+	}
+	return spec
+}
+
+//the filters work on streams so that a message never has to fit in memory: each of them is a state machine which
+//receives the message one rune at a time and tells what happens to each rune. its state carries from one rune to the
+//next, so it doesn't matter how the message was cut in chunks when it was read
+//
+//a transformer may wait for the following runes before deciding, for example to recognise a delimiter made of
+//several runes, but it must decide in order and must have decided for every rune when End returns. the bytes of the
+//message which aren't valid UTF-8 are fed as the runes IsRawByte recognises
+type Transformer interface {
+	Feed(r rune, out Emitter) error
+	End(out Emitter) error
+}
+
+//an Emitter receives the decisions of a transformer, in the order of the message. besides keeping or removing each
+//rune it received, a transformer can insert text which wasn't in its input, before the next rune it decides for
+type Emitter interface {
+	Keep(r rune) error
+	Remove(r rune) error
+	Insert(s string) error
+}
+
+//a filter which decides for each rune alone, so its transformer has no state
+type RuneFilter func(r rune) (keep bool)
+
+func (f RuneFilter) Feed(r rune, out Emitter) error {
+	if f(r) {
+		return out.Keep(r)
+	}
+	return out.Remove(r)
+}
+
+func (f RuneFilter) End(out Emitter) error {
+	return nil
+}
+
+//the bytes which aren't valid UTF-8 go through the filters as runes of their own, taken among the halves of
+//surrogate pairs which can't be in a valid message, so that they are written back unchanged instead of becoming
+//U+FFFD. this is what Python calls surrogateescape
+const rawByteRunes = 0xdc00
+
+//reads the next rune, or the next byte when it isn't valid UTF-8
+func ReadRune(reader *bufio.Reader) (rune, error) {
+	r, size, err := reader.ReadRune()
+	if err != nil || r != utf8.RuneError || size != 1 {
+		return r, err
+	}
+	reader.UnreadRune()
+	b, err := reader.ReadByte()
+	return rawByteRunes + rune(b), err
+}
+
+//does the same as ReadRune at the start of s, and also returns the size of the rune in s
+func DecodeRune(s string) (rune, int) {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError && size == 1 {
+		return rawByteRunes + rune(s[0]), 1
+	}
+	return r, size
+}
+
+//returns whether r stands for a byte which isn't valid UTF-8
+func IsRawByte(r rune) bool {
+	return r >= rawByteRunes+0x80 && r <= rawByteRunes+0xff //only these bytes can be invalid
+}
+
+//the number of bytes WriteRune writes for r
+func RuneSize(r rune) int {
+	if IsRawByte(r) {
+		return 1
+	}
+	return utf8.RuneLen(r)
+}
+
+//what both bufio.Writer and strings.Builder are
+type RuneWriter interface {
+	WriteByte(c byte) error
+	WriteRune(r rune) (int, error)
+}
+
+//writes r, or the byte it stands for
+func WriteRune(w RuneWriter, r rune) error {
+	if IsRawByte(r) {
+		return w.WriteByte(byte(r - rawByteRunes))
+	}
+	_, err := w.WriteRune(r)
+	return err
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//an argument of a filter in a pipeline
+type Arg struct {
+	Text   string
+	Quoted bool //it was written as a string, so it can't be an option
+}
+
+//a noisy message with what it should become once cleaned, from which -learn looks for a pipeline
+type Example struct {
+	Noisy string
+	Clean string
+}
+
+//a Kind of filter can be used in pipelines by its name. Parse receives the arguments following the name
+type Kind struct {
+	Name        string
+	Usage       string //the arguments it expects
+	Description string
+	Parse       func(args []Arg) (Filter, error)
+	Candidates  func(examples []Example) []Filter //the filters worth trying to learn a pipeline, nil if none
+}
+
+//the kinds of filters, by name and by alias. each filter registers itself in an init function, so that adding a
+//filter doesn't require changing the parser
+var kinds = make(map[string]Kind)
+
+//makes the kind usable in pipelines under its name and its aliases. it panics when one of them is taken, as init
+//functions can't return errors
+func Register(kind Kind, aliases ...string) {
+	for _, name := range append([]string{kind.Name}, aliases...) {
+		if _, ok := kinds[name]; ok {
+			panic("filter " + name + " is registered twice")
+		}
+		kinds[name] = kind
+	}
+}
+
+//returns the kind registered under the name or the alias
+func Lookup(name string) (Kind, bool) {
+	kind, ok := kinds[name]
+	return kind, ok
+}
+
+//builds the filter called name in a pipeline from its arguments
+func New(name string, args []Arg) (Filter, error) {
+	kind, ok := kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return kind.Parse(args)
+}
+
+//returns the names of the filters, without the aliases, in order
+func Names() []string {
+	names := make([]string, 0, len(kinds))
+	for name, kind := range kinds {
+		if name == kind.Name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//returns the aliases of the filter called name, in order
+func Aliases(name string) []string {
+	aliases := make([]string, 0)
+	for alias, kind := range kinds {
+		if alias != name && kind.Name == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+//checks the number of arguments of a filter which has no options
+func CheckArgs(name string, args []Arg, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("wrong number of arguments for %s, expected %d", name, n)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//the number of runes which disappear from the examples that the candidates are made of
+const learnedRunes = 6
+
+//returns the description the filter called name was registered with
+func describe(name string) string {
+	kind, _ := filter.Lookup(name)
+	return kind.Description
+}
+
+//prints every filter with its aliases, its arguments and what it does
+func listFilters(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\targuments\tdescription")
+	for _, name := range filter.Names() {
+		kind, _ := filter.Lookup(name)
+		if aliases := filter.Aliases(name); len(aliases) > 0 {
+			name += " (" + strings.Join(aliases, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, kind.Usage, kind.Description)
+	}
+	return tw.Flush()
+}
+
+func init() {
+	filter.Register(filter.Kind{
+		Name:        "strip-chars",
+		Usage:       `"chars" [grapheme]`,
+		Description: "removes every occurrence of each of the characters",
+		Parse: func(args []filter.Arg) (filter.Filter, error) {
+			grapheme := len(args) == 2 && args[1].Text == "grapheme" && !args[1].Quoted
+			if !grapheme {
+				if err := filter.CheckArgs("strip-chars", args, 1); err != nil {
+					return nil, err
+				}
+			}
+			return stripCharsFilter{chars: args[0].Text, grapheme: grapheme}, nil
+		},
+		Candidates: func(examples []filter.Example) []filter.Filter { //each rune which disappears, then all of them
+			runes := removedRunes(examples, learnedRunes)
+			candidates := make([]filter.Filter, 0, len(runes)+1)
+			for _, r := range runes {
+				candidates = append(candidates, stripCharsFilter{chars: string(r)})
+			}
+			if len(runes) > 1 {
+				candidates = append(candidates, stripCharsFilter{chars: string(runes)})
+			}
+			return candidates
+		},
+	}, "strip")
+	filter.Register(filter.Kind{
+		Name:        "collapse-spaces",
+		Description: "removes the white space following white space, so that only the first of a run is kept",
+		Parse: func(args []filter.Arg) (filter.Filter, error) {
+			if err := filter.CheckArgs("collapse-spaces", args, 0); err != nil {
+				return nil, err
+			}
+			return collapseSpacesFilter{}, nil
+		},
+		Candidates: func(examples []filter.Example) []filter.Filter {
+			return []filter.Filter{collapseSpacesFilter{}}
+		},
+	})
+	filter.Register(filter.Kind{
+		Name:        "strip-nonprintable",
+		Description: "removes control characters and every other character which can't be printed, except spaces",
+		Parse: func(args []filter.Arg) (filter.Filter, error) {
+			if err := filter.CheckArgs("strip-nonprintable", args, 0); err != nil {
+				return nil, err
+			}
+			return stripNonprintableFilter{}, nil
+		},
+		Candidates: func(examples []filter.Example) []filter.Filter {
+			return []filter.Filter{stripNonprintableFilter{}}
+		},
+	})
+}
+
+type stripCharsFilter struct {
+	chars    string
+	grapheme bool //the characters are grapheme clusters, which are only removed as a whole
+}
+
+func (f stripCharsFilter) Name() string {
+	return "strip-chars"
+}
+
+func (f stripCharsFilter) Description() string {
+	return describe("strip-chars")
+}
+
+func (f stripCharsFilter) Params() []filter.Param {
+	params := []filter.Param{{Name: "chars", Value: f.chars, Positional: true}}
+	if f.grapheme {
+		params = append(params, filter.Param{Name: "grapheme", Flag: true})
+	}
+	return params
+}
+
+func (f stripCharsFilter) NewTransformer() filter.Transformer {
+	if f.grapheme {
+		clusters := make(map[string]bool)
+		for _, cluster := range splitClusters(f.chars) {
+			clusters[cluster] = true
+		}
+		return &clusterTransformer{decide: func(cluster []rune) bool {
+			return !clusters[string(cluster)]
+		}}
+	}
+	return filter.RuneFilter(func(r rune) bool {
+		return !strings.ContainsRune(f.chars, r)
+	})
+}
+
+type collapseSpacesFilter struct{}
+
+func (f collapseSpacesFilter) Name() string {
+	return "collapse-spaces"
+}
+
+func (f collapseSpacesFilter) Description() string {
+	return describe("collapse-spaces")
+}
+
+func (f collapseSpacesFilter) Params() []filter.Param {
+	return nil
+}
+
+func (f collapseSpacesFilter) NewTransformer() filter.Transformer {
+	afterSpace := false
+	return filter.RuneFilter(func(r rune) bool {
+		space := unicode.IsSpace(r)
+		keep := !space || !afterSpace
+		afterSpace = space
+		return keep
+	})
+}
+
+type stripNonprintableFilter struct{}
+
+func (f stripNonprintableFilter) Name() string {
+	return "strip-nonprintable"
+}
+
+func (f stripNonprintableFilter) Description() string {
+	return describe("strip-nonprintable")
+}
+
+func (f stripNonprintableFilter) Params() []filter.Param {
+	return nil
+}
+
+func (f stripNonprintableFilter) NewTransformer() filter.Transformer {
+	return filter.RuneFilter(func(r rune) bool {
+		return unicode.IsPrint(r) || unicode.IsSpace(r) && !unicode.IsControl(r)
+	})
+}
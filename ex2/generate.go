@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+//the generator does the opposite of the filters: it adds interferences to clean messages, so that we can build
+//corpora to check pipelines against. the noise is described with the same language as pipelines, each kind of noise
+//being tried with its rate before every rune of the message and at its end:
+//	char "." 0.1                 inserts one of the characters
+//	span "*" "*" 0.05 length=1-5 inserts a span of random text between the two delimiters
+//the same seed always gives the same noise
+
+//this is the noise the original problem removes
+const defaultNoise = `char "." 0.1 | span "*" "*" 0.05`
+
+const (
+	generateRecords  = "records"  //the noisy messages as ex2 records, the clean ones can go to another file
+	generateExamples = "examples" //pairs of lines, a noisy message then the clean one, as -learn reads them
+)
+
+//the runes the text of spans is made of
+const spanAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+type noise struct {
+	kind     string //char or span
+	chars    []rune //for char
+	open     string //for span
+	close    string
+	rate     float64
+	min, max int //length of the text of a span, in runes
+}
+
+func parseNoise(spec string) ([]noise, error) {
+	tokens, err := tokenize(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	noises := make([]noise, 0)
+	for len(tokens) > 0 {
+		end := 0
+		for end < len(tokens) && !tokens[end].pipe {
+			end++
+		}
+		if end == 0 || end == len(tokens)-1 {
+			return nil, fmt.Errorf("column %d: missing noise", tokens[end].column)
+		}
+		n, err := newNoise(tokens[0].text, tokens[1:end])
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", tokens[0].column, err)
+		}
+		noises = append(noises, n)
+		if end == len(tokens) {
+			break
+		}
+		tokens = tokens[end+1:]
+	}
+	if len(noises) == 0 {
+		return nil, fmt.Errorf("the noise is empty")
+	}
+	return noises, nil
+}
+
+func newNoise(kind string, args []token) (noise, error) {
+	n := noise{kind: kind, min: 1, max: 5}
+	var rate token
+	switch {
+	case kind == "char" && len(args) == 2:
+		n.chars, rate = []rune(args[0].text), args[1]
+		if len(n.chars) == 0 {
+			return n, fmt.Errorf("char needs at least one character")
+		}
+	case kind == "span" && (len(args) == 3 || len(args) == 4):
+		n.open, n.close, rate = args[0].text, args[1].text, args[2]
+		if len(args) == 4 {
+			bounds := strings.TrimPrefix(args[3].text, "length=")
+			i := strings.IndexByte(bounds, '-')
+			var err1, err2 error
+			if i >= 0 {
+				n.min, err1 = strconv.Atoi(bounds[:i])
+				n.max, err2 = strconv.Atoi(bounds[i+1:])
+			}
+			if !strings.HasPrefix(args[3].text, "length=") || i < 0 || err1 != nil || err2 != nil ||
+				n.min < 0 || n.max < n.min {
+				return n, fmt.Errorf("invalid option %q for span, expected length=min-max", args[3].text)
+			}
+		}
+	case kind == "char", kind == "span":
+		return n, fmt.Errorf("wrong number of arguments for %s", kind)
+	default:
+		return n, fmt.Errorf("unknown noise %q, expected char or span", kind)
+	}
+
+	var err error
+	n.rate, err = strconv.ParseFloat(rate.text, 64)
+	if err != nil || n.rate < 0 || n.rate > 1 {
+		return n, fmt.Errorf("the rate of %s must be between 0 and 1, got %q", kind, rate.text)
+	}
+	return n, nil
+}
+
+//writes the noise n before the next rune
+func (n noise) inject(builder *strings.Builder, random *rand.Rand) {
+	if n.kind == "char" {
+		builder.WriteRune(n.chars[random.Intn(len(n.chars))])
+		return
+	}
+	builder.WriteString(n.open)
+	length := n.min + random.Intn(n.max-n.min+1)
+	for i := 0; i < length; i++ {
+		builder.WriteByte(spanAlphabet[random.Intn(len(spanAlphabet))])
+	}
+	builder.WriteString(n.close)
+}
+
+func addNoise(clean string, noises []noise, random *rand.Rand) string {
+	var builder strings.Builder
+	injectAll := func() {
+		for _, n := range noises {
+			if random.Float64() < n.rate {
+				n.inject(&builder, random)
+			}
+		}
+	}
+	for _, r := range clean {
+		injectAll()
+		builder.WriteRune(r)
+	}
+	injectAll()
+	return builder.String()
+}
+
+//reads clean messages from in, one per line, and writes them with noise to out in the given format. with records,
+//the clean messages are also written as records to expected when it isn't nil, so that it is what -batch records
+//should give
+func generate(in io.Reader, out, expected io.Writer, format string, noises []noise, seed int64) error {
+	random := rand.New(rand.NewSource(seed))
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	var expectedWriter *bufio.Writer
+	if expected != nil {
+		expectedWriter = bufio.NewWriter(expected)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		clean := strings.TrimSuffix(line, "\n")
+		noisy := addNoise(clean, noises, random)
+
+		switch format {
+		case generateRecords:
+			fmt.Fprintf(writer, "%d\n%s\n", len(noisy), noisy)
+			if expectedWriter != nil {
+				fmt.Fprintf(expectedWriter, "%d\n%s\n", len(clean), clean)
+			}
+		case generateExamples:
+			fmt.Fprintf(writer, "%s\n%s\n", noisy, clean)
+		}
+	}
+
+	if expectedWriter != nil {
+		if err := expectedWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
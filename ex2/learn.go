@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//the learning mode looks for a pipeline from examples of noisy messages with what they should become. each kind of
+//filter proposes a few candidates from the examples, such as stripping a character which disappears, and we try
+//every sequence of them, the shortest first, until one turns every noisy message into its clean counterpart. when
+//there is none, we report the sequence which was the closest
+
+//reads examples written as pairs of lines: the noisy message, then the clean one
+func readExamples(r io.Reader) ([]filter.Example, error) {
+	reader := bufio.NewReader(r)
+	lines := make([]string, 0)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if len(lines) == 0 || len(lines)%2 != 0 {
+		return nil, fmt.Errorf("the examples must be pairs of lines, a noisy message then the clean one, got %d lines",
+			len(lines))
+	}
+
+	examples := make([]filter.Example, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		examples = append(examples, filter.Example{Noisy: lines[i], Clean: lines[i+1]})
+	}
+	return examples, nil
+}
+
+func readExamplesFile(path string) ([]filter.Example, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	examples, err := readExamples(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return examples, nil
+}
+
+type learnResult struct {
+	filters  []filter.Filter
+	matches  int //number of examples it cleans exactly
+	distance int //sum of the edit distances between what it gives and the clean messages
+}
+
+//returns whether r is better than other: it matches more examples, or it is closer to them, or it is shorter
+func (r learnResult) betterThan(other learnResult) bool {
+	if r.matches != other.matches {
+		return r.matches > other.matches
+	}
+	if r.distance != other.distance {
+		return r.distance < other.distance
+	}
+	return len(r.filters) < len(other.filters)
+}
+
+//looks for the shortest pipeline of at most maxFilters filters which cleans every example. when there is none, the
+//result is the best partial match
+func learnPipeline(examples []filter.Example, maxFilters int) learnResult {
+	//the kinds which propose fewer candidates are the simpler ones, so we try them first as the first pipeline found
+	//is the one we keep
+	kinds := make([][]filter.Filter, 0)
+	for _, name := range filter.Names() {
+		if kind, _ := filter.Lookup(name); kind.Candidates != nil {
+			kinds = append(kinds, kind.Candidates(examples))
+		}
+	}
+	sort.SliceStable(kinds, func(i, j int) bool {
+		return len(kinds[i]) < len(kinds[j])
+	})
+	candidates := make([]filter.Filter, 0)
+	for _, kind := range kinds {
+		candidates = append(candidates, kind...)
+	}
+
+	noisy := make([]string, len(examples))
+	for i, e := range examples {
+		noisy[i] = e.Noisy
+	}
+	best := scorePipeline(examples, nil, noisy)
+
+	//iterative deepening: the first pipeline which matches everything is one of the shortest
+	for depth := 1; depth <= maxFilters && best.matches < len(examples); depth++ {
+		searchPipelines(examples, candidates, nil, noisy, depth, &best)
+	}
+	return best
+}
+
+//tries every way of completing pipeline, whose outputs on the examples are given, with up to depth filters
+func searchPipelines(examples []filter.Example, candidates []filter.Filter, pipeline []filter.Filter, outputs []string,
+	depth int, best *learnResult) bool {
+	if depth == 0 {
+		return false
+	}
+	for _, f := range candidates {
+		next, ok := applyCandidate(examples, f, outputs)
+		if !ok {
+			continue
+		}
+		extended := append(append([]filter.Filter(nil), pipeline...), f)
+		if result := scorePipeline(examples, extended, next); result.betterThan(*best) {
+			*best = result
+			if result.matches == len(examples) {
+				return true
+			}
+		}
+		if searchPipelines(examples, candidates, extended, next, depth-1, best) {
+			return true
+		}
+	}
+	return false
+}
+
+//applies a filter to the current outputs. it is useless when it changes none of them or when it is rejected, and we
+//can also give up when a clean message can no longer be obtained: the candidates only remove runes, so each clean
+//message must stay a subsequence of its output
+func applyCandidate(examples []filter.Example, f filter.Filter, outputs []string) ([]string, bool) {
+	next := make([]string, len(outputs))
+	changed := false
+	for i, output := range outputs {
+		cleaned, err := removeInterferences(output, f)
+		if err != nil || !isSubsequence(examples[i].Clean, cleaned) {
+			return nil, false
+		}
+		next[i] = cleaned
+		changed = changed || cleaned != output
+	}
+	return next, changed
+}
+
+func scorePipeline(examples []filter.Example, pipeline []filter.Filter, outputs []string) learnResult {
+	result := learnResult{filters: pipeline}
+	for i, output := range outputs {
+		if output == examples[i].Clean {
+			result.matches++
+		} else {
+			result.distance += editDistance(output, examples[i].Clean)
+		}
+	}
+	return result
+}
+
+func isSubsequence(sub, s string) bool {
+	runes := []rune(sub)
+	i := 0
+	for _, r := range s {
+		if i < len(runes) && runes[i] == r {
+			i++
+		}
+	}
+	return i == len(runes)
+}
+
+//Levenshtein distance, on runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+//returns the runes which disappear from at least one example, those which disappear the most first. at most limit
+//of them are returned, as the number of candidates grows quickly with them
+func removedRunes(examples []filter.Example, limit int) []rune {
+	removed := make(map[rune]int)
+	for _, e := range examples {
+		counts := make(map[rune]int)
+		for _, r := range e.Noisy {
+			counts[r]++
+		}
+		for _, r := range e.Clean {
+			counts[r]--
+		}
+		for r, n := range counts {
+			if n > 0 {
+				removed[r] += n
+			}
+		}
+	}
+
+	runes := make([]rune, 0, len(removed))
+	for r := range removed {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		if removed[runes[i]] != removed[runes[j]] {
+			return removed[runes[i]] > removed[runes[j]]
+		}
+		return runes[i] < runes[j]
+	})
+	if len(runes) > limit {
+		runes = runes[:limit]
+	}
+	return runes
+}
+
+//prints the pipeline found, followed by how well it does as a comment, so that the output can be given to
+//-pipeline-file as it is
+func printLearnResult(out io.Writer, result learnResult, examples int) error {
+	specs := make([]string, len(result.filters))
+	for i, f := range result.filters {
+		specs[i] = filter.Spec(f)
+	}
+	if len(specs) == 0 { //an empty pipeline can't be written, but it can do best when the messages are already clean
+		fmt.Fprintln(out, `strip ""`)
+	} else {
+		fmt.Fprintln(out, strings.Join(specs, " | "))
+	}
+	if result.matches == examples {
+		_, err := fmt.Fprintf(out, "# matches every example (%d)\n", examples)
+		return err
+	}
+	_, err := fmt.Fprintf(out, "# matches %d of the %d examples, with an edit distance of %d on the others\n",
+		result.matches, examples, result.distance)
+	return err
+}
@@ -2,51 +2,244 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
 )
 
 func main() {
-	input := getInput() //we read from stdin
-	fmt.Println(removeInterferences( //and remove interferences
-		input,
-		func(message string) (cleaned string) { //this filter removes the dots
-			cleaned = strings.Replace(message, ".", "", -1)
-			return
-		},
-		func(message string) (cleaned string) { //this one removes characters between two '*'
-			toKeep := make([]string, 0)
-
-			var strSplit []string
-			strSplit = strings.Split(message, "*")
-
-			for i, s := range strSplit { //we keep only the even indexed strings
-				if i%2 == 0 {
-					toKeep = append(toKeep, s)
-				}
-			}
-			return strings.Join(toKeep, "") //and join them
-		}))
+	pipeline := flag.String("pipeline", defaultPipeline, "filters to apply, such as "+
+		`'strip "." | between "*" "*" | between "[" "]"'`)
+	pipelineFile := flag.String("pipeline-file", "", "read the filters to apply from this file")
+	traceFormat := flag.String("trace", "", "show what each filter removed instead of the cleaned message, "+
+		"as json or text")
+	mapFile := flag.String("map", "", "also write what was removed to this file, so that the message can be restored")
+	uncleanFile := flag.String("unclean", "", "restore the original message from the cleaned one and this removal map, "+
+		"reading the cleaned message from stdin as -map prints it")
+	translateFile := flag.String("translate", "", "translate the offsets given as arguments, written original:N "+
+		"or cleaned:N in bytes and original-rune:N or cleaned-rune:N in runes, with this removal map")
+	list := flag.Bool("list-filters", false, "list the filters which can be used in pipelines")
+	batchFormat := flag.String("batch", "", "clean many messages, either one per line with lines or as records "+
+		"with records, each made of a line with the length of the message and the message")
+	learnFile := flag.String("learn", "", "find a pipeline from the examples in this file, made of pairs of lines: "+
+		"a noisy message then the clean one. it tries the filters which remove characters, but not regex")
+	maxFilters := flag.Int("max-filters", 3, "maximum number of filters of the pipeline found by -learn")
+	generateFormat := flag.String("generate", "", "add noise to the clean messages read from stdin, one per line, "+
+		"and write them as records or as examples for -learn")
+	noiseSpec := flag.String("noise", defaultNoise, "the noise added by -generate")
+	seed := flag.Int64("seed", 1, "seed of the noise added by -generate and of the messages of -analyze")
+	analyze := flag.Bool("analyze", false, "check on random messages which filters of the pipeline commute and "+
+		"which are idempotent, instead of cleaning")
+	samples := flag.Int("samples", 2000, "number of random messages of -analyze")
+	expectedFile := flag.String("expected", "", "with -generate records, also write the clean messages as records "+
+		"to this file")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of messages cleaned at the same time in batch mode")
+	flag.Parse()
+
+	if *list {
+		exitOnError(listFilters(os.Stdout))
+		return
+	}
+
+	if *generateFormat != "" { //these modes don't clean anything
+		if *learnFile != "" || *translateFile != "" || *uncleanFile != "" {
+			exitOnError(fmt.Errorf("-generate can't be used with -learn, -translate or -unclean"))
+		}
+		if *generateFormat != generateRecords && *generateFormat != generateExamples {
+			exitOnError(fmt.Errorf("unknown format %q for -generate, expected %s or %s",
+				*generateFormat, generateRecords, generateExamples))
+		}
+		if *expectedFile != "" && *generateFormat != generateRecords {
+			exitOnError(fmt.Errorf("-expected can only be used with -generate %s", generateRecords))
+		}
+		noises, err := parseNoise(*noiseSpec)
+		exitOnError(err)
+
+		var expected io.Writer
+		if *expectedFile != "" {
+			file, err := os.Create(*expectedFile)
+			exitOnError(err)
+			defer file.Close()
+			expected = file
+		}
+		exitOnError(generate(os.Stdin, os.Stdout, expected, *generateFormat, noises, *seed))
+		return
+	}
+	if *learnFile != "" {
+		if isFlagSet("pipeline") || *pipelineFile != "" || *translateFile != "" || *uncleanFile != "" {
+			exitOnError(fmt.Errorf("-learn can't be used with -pipeline, -pipeline-file, -translate or -unclean"))
+		}
+		examples, err := readExamplesFile(*learnFile)
+		exitOnError(err)
+		result := learnPipeline(examples, *maxFilters)
+		exitOnError(printLearnResult(os.Stdout, result, len(examples)))
+		return
+	}
+	if *translateFile != "" {
+		if *uncleanFile != "" || *mapFile != "" || *traceFormat != "" {
+			exitOnError(fmt.Errorf("-translate can't be used with -unclean, -map or -trace"))
+		}
+		m, err := readRemovalMap(*translateFile)
+		exitOnError(err)
+		exitOnError(printTranslations(m, flag.Args()))
+		return
+	}
+	if *uncleanFile != "" {
+		if *mapFile != "" || *traceFormat != "" {
+			exitOnError(fmt.Errorf("-unclean can't be used with -map or -trace"))
+		}
+		//we read the whole output of -map, which is the message and a newline, before the map: when the output
+		//is piped to us, -map has written the map once it closed it
+		cleaned, err := io.ReadAll(os.Stdin)
+		exitOnError(err)
+		m, err := readRemovalMap(*uncleanFile)
+		exitOnError(err)
+		original, err := Unclean(strings.TrimSuffix(string(cleaned), "\n"), m)
+		exitOnError(err)
+		fmt.Println(original)
+		return
+	}
+
+	spec := *pipeline
+	if *pipelineFile != "" {
+		if isFlagSet("pipeline") {
+			exitOnError(fmt.Errorf("-pipeline and -pipeline-file can't be used together"))
+		}
+		content, err := os.ReadFile(*pipelineFile)
+		exitOnError(err)
+		spec = string(content)
+	}
+	filters, err := parsePipeline(spec) //we build the filters before reading anything
+	exitOnError(err)
+
+	if *analyze {
+		if *batchFormat != "" || *traceFormat != "" || *mapFile != "" {
+			exitOnError(fmt.Errorf("-analyze can't be used with -batch, -trace or -map"))
+		}
+		if *samples < 1 {
+			exitOnError(fmt.Errorf("-samples must be at least 1"))
+		}
+		exitOnError(analyzePipeline(filters, *samples, *seed).write(os.Stdout))
+		return
+	}
+	if *batchFormat != "" {
+		if *traceFormat != "" || *mapFile != "" {
+			exitOnError(fmt.Errorf("-batch can't be used with -trace or -map"))
+		}
+		if *batchFormat != batchLines && *batchFormat != batchRecords {
+			exitOnError(fmt.Errorf("unknown batch format %q, expected %s or %s", *batchFormat, batchLines, batchRecords))
+		}
+		if *workers < 1 {
+			exitOnError(fmt.Errorf("-workers must be at least 1"))
+		}
+		total, rejected, err := runBatch(os.Stdin, os.Stdout, *batchFormat, *workers, filters)
+		exitOnError(err)
+		if rejected > 0 {
+			exitOnError(fmt.Errorf("%d of the %d messages were rejected", rejected, total))
+		}
+		return
+	}
+
+	if *traceFormat != "" && *mapFile != "" {
+		exitOnError(fmt.Errorf("-trace and -map can't be used together"))
+	}
+	if *traceFormat != "" { //the trace needs the whole message
+		message, err := io.ReadAll(getInput())
+		exitOnError(err)
+		trace, traceErr := traceInterferences(string(message), filters...)
+		exitOnError(trace.write(os.Stdout, *traceFormat))
+		exitOnError(traceErr) //a rejected message is shown in the trace but is still an error
+		return
+	}
+
+	if *mapFile != "" { //the map needs the whole message too
+		message, err := io.ReadAll(getInput())
+		exitOnError(err)
+		cleaned, m, err := removeInterferencesWithMap(string(message), filters...)
+		exitOnError(err)
+		exitOnError(writeRemovalMap(*mapFile, m))
+		fmt.Println(cleaned)
+		return
+	}
+
+	//the message is cleaned while it is read, so that its length doesn't matter
+	_, err = io.Copy(os.Stdout, newCleaningReader(getInput(), filters...))
+	exitOnError(err)
+	fmt.Println()
+}
+
+//returns a reader of the message, which is the second line of stdin
+func getInput() io.Reader {
+	reader := bufio.NewReader(os.Stdin)
+
+	_, err := reader.ReadString('\n') //we discard the first line, as the message ends with the line anyway
+	if err != nil && err != io.EOF {
+		exitOnError(err)
+	}
+
+	return lineReader{reader} //and stop at the \n at the end of the input
 }
 
-func getInput() (inputStr string) {
-	scanner := bufio.NewScanner(os.Stdin)
+//reads until the end of the current line, without returning the \n nor the \r before it
+type lineReader struct {
+	reader *bufio.Reader
+}
 
-	scanner.Scan() //we scan twice to discard the first line, as the range operator will determine
-	scanner.Scan() 	//the length automatically
+func (l lineReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		c, err := l.reader.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if c == '\r' {
+			next, err := l.reader.Peek(1)
+			if err == io.EOF || err == nil && next[0] == '\n' { //the line ends with \r\n, or the input with \r
+				c = '\n'
+			}
+		}
+		if c == '\n' {
+			l.reader.UnreadByte() //so that every following call also stops here
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		}
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
 
-	inputStr = scanner.Text()
-	inputStr = strings.Replace(inputStr, "\n", "", -1) //we replace the \n at the end of the input
+//This functions takes as input a string and any number of filters
+//the string will be passed through each filter which should remove all the different kind of interferences
+//we stop at the first filter which rejects the message
+func removeInterferences(message string, filters ...filter.Filter) (string, error) {
+	var cleaned strings.Builder
+	if err := cleanStream(strings.NewReader(message), &cleaned, filters...); err != nil {
+		return "", err
+	}
+	return cleaned.String(), nil
+}
 
-	return inputStr
+//returns whether the flag was given on the command line
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-//This functions takes as input a string and any number of functions
-//the string will be passed through each function which should remove all the different kind of interferences
-func removeInterferences(message string, filters ...func(message string) (cleaned string)) string {
-	for _, f := range filters { //for each filter
-		message = f(message) //we pass the message though it
+//prints err and stops the program if it isn't nil
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ex2:", err)
+		os.Exit(1)
 	}
-	return message
 }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//what the regex filter does with the matches of its pattern
+const (
+	regexRemove   = "remove"    //they are removed
+	regexReplace  = "replace"   //they are replaced by a template, where $1 or ${name} are the groups of the match
+	regexKeepOnly = "keep-only" //everything else is removed
+)
+
+//a regular expression can't be matched on a stream, so the runes wait in a window until there are twice this many of
+//them. we match the pattern on the window but only decide for the runes before the last regexChunkSize ones, and for
+//the matches starting there: the runes left are matched again with the following ones. this gives the same matches as
+//the whole message as long as none of them can be longer than regexChunkSize runes. a pattern such as x.*y can match
+//more, and its matches then stop where the window ends or earlier, which we can only report when it ends exactly there.
+//the assertions such as ^ or \b depend on the runes around them, which aren't in the window once it doesn't start at
+//the beginning of the message, so a pattern using them is an error on messages which don't fit in the first window
+const regexChunkSize = 64 * 1024
+
+func init() {
+	filter.Register(filter.Kind{
+		Name:  "regex",
+		Usage: `"pattern" [replace="template" | keep-only]`,
+		Description: "removes the matches of a Go regular expression, replaces them, or keeps only them. on messages " +
+			"longer than 131071 runes, a match longer than 65536 runes, such as x.*y over most of it, may be cut short",
+		Parse: func(args []filter.Arg) (filter.Filter, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("wrong number of arguments for regex, expected at least 1")
+			}
+			return parseRegexFilter(args[0].Text, args[1:])
+		},
+	})
+}
+
+type regexFilter struct {
+	pattern    *regexp.Regexp
+	mode       string
+	template   string //only used to replace
+	assertions bool   //whether the pattern uses ^, $, \A, \z, \b or \B
+}
+
+//compiles the pattern when the pipeline is loaded, so that a wrong pattern is reported before reading anything
+func parseRegexFilter(pattern string, args []filter.Arg) (filter.Filter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for regex: %v", err)
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil { //it compiled, so it can't happen
+		return nil, fmt.Errorf("invalid pattern for regex: %v", err)
+	}
+	f := regexFilter{pattern: re, mode: regexRemove, assertions: hasAssertions(parsed)}
+	for _, arg := range args {
+		switch {
+		case arg.Text == regexKeepOnly && !arg.Quoted && f.mode == regexRemove:
+			f.mode = regexKeepOnly
+		case strings.HasPrefix(arg.Text, "replace=") && !arg.Quoted && f.mode == regexRemove:
+			f.mode, f.template = regexReplace, strings.TrimPrefix(arg.Text, "replace=")
+		default:
+			return nil, fmt.Errorf("unexpected option %q for regex, expected either replace=\"template\" or keep-only",
+				arg.Text)
+		}
+	}
+	return f, nil
+}
+
+func hasAssertions(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary,
+		syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasAssertions(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f regexFilter) Name() string {
+	return "regex"
+}
+
+func (f regexFilter) Description() string {
+	return describe("regex")
+}
+
+func (f regexFilter) Params() []filter.Param {
+	params := []filter.Param{{Name: "pattern", Value: f.pattern.String(), Positional: true}}
+	switch f.mode {
+	case regexReplace:
+		params = append(params, filter.Param{Name: regexReplace, Value: f.template})
+	case regexKeepOnly:
+		params = append(params, filter.Param{Name: regexKeepOnly, Flag: true})
+	}
+	return params
+}
+
+func (f regexFilter) NewTransformer() filter.Transformer {
+	return &regexTransformer{filter: f}
+}
+
+type regexTransformer struct {
+	filter   regexFilter
+	pending  []rune
+	moved    bool //whether the window no longer starts at the beginning of the message
+	abutting bool //whether it starts at the end of a match, so that an empty match there doesn't count
+}
+
+func (t *regexTransformer) Feed(r rune, out filter.Emitter) error {
+	t.pending = append(t.pending, r)
+	if len(t.pending) >= 2*regexChunkSize {
+		return t.flush(out, false)
+	}
+	return nil
+}
+
+func (t *regexTransformer) End(out filter.Emitter) error {
+	return t.flush(out, true)
+}
+
+//matches the pattern on the pending runes and decides for them: all of them at the end of the message, otherwise
+//those before the last regexChunkSize runes and those of the matches starting before them
+func (t *regexTransformer) flush(out filter.Emitter, final bool) error {
+	if t.filter.assertions && (t.moved || !final) {
+		return fmt.Errorf("the pattern uses ^, $, \\A, \\z, \\b or \\B, which can't be matched on messages longer "+
+			"than %d runes", 2*regexChunkSize-1)
+	}
+
+	var builder strings.Builder
+	cut := len(t.pending) //in runes, we decide for the runes before it, or the matches starting before it
+	for i, r := range t.pending {
+		if i == len(t.pending)-regexChunkSize && !final {
+			cut = i
+		}
+		filter.WriteRune(&builder, r)
+	}
+	chunk := builder.String()
+	matches := t.filter.pattern.FindAllStringSubmatchIndex(chunk, -1)
+	next := 0 //the first match we didn't reach
+	if t.abutting && len(matches) > 0 && matches[0][1] == 0 {
+		next = 1 //the previous window ended with a match, and the whole message has no empty match right after it
+	}
+	matchEnd := 0
+
+	//starts the matches beginning at pos. there can be several of them when they are empty, which matters when they
+	//are replaced
+	startMatches := func(pos int) error {
+		for next < len(matches) && matches[next][0] == pos {
+			match := matches[next]
+			next++
+			if t.filter.mode == regexReplace {
+				replacement := t.filter.pattern.ExpandString(nil, t.filter.template, chunk, match)
+				if err := out.Insert(string(replacement)); err != nil {
+					return err
+				}
+			}
+			if match[1] > pos {
+				matchEnd = match[1]
+				return nil
+			}
+		}
+		return nil
+	}
+
+	pos := 0
+	decided := 0
+	for i, r := range t.pending {
+		if i >= cut && pos >= matchEnd { //the following runes will be matched again with the next window
+			break
+		}
+		if pos >= matchEnd {
+			if err := startMatches(pos); err != nil {
+				return err
+			}
+		}
+		keep := pos >= matchEnd //outside of the matches
+		if t.filter.mode == regexKeepOnly {
+			keep = !keep
+		}
+
+		var err error
+		if keep {
+			err = out.Keep(r)
+		} else {
+			err = out.Remove(r)
+		}
+		if err != nil {
+			return err
+		}
+		pos += filter.RuneSize(r)
+		decided++
+	}
+	if final {
+		return startMatches(pos) //the empty matches at the end
+	}
+	if matchEnd == len(chunk) { //the match could have gone on in the following runes
+		return fmt.Errorf("a match is longer than %d runes", regexChunkSize)
+	}
+
+	t.abutting = matchEnd > 0 && matchEnd == pos
+	t.pending = append(t.pending[:0], t.pending[decided:]...)
+	t.moved = true
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//a message of length runes, made of filler with texts written over it at the given rune positions
+func messageWith(filler rune, length int, texts map[int]string) string {
+	runes := []rune(strings.Repeat(string(filler), length))
+	for at, text := range texts {
+		copy(runes[at:], []rune(text))
+	}
+	return string(runes)
+}
+
+//what the regex filter should give, from the matches on the whole message
+func regexOnWhole(pattern, option, message string) string {
+	re := regexp.MustCompile(pattern)
+	switch {
+	case option == regexKeepOnly:
+		return strings.Join(re.FindAllString(message, -1), "")
+	case strings.HasPrefix(option, "replace="):
+		return re.ReplaceAllString(message, strings.TrimPrefix(option, "replace="))
+	}
+	return re.ReplaceAllString(message, "")
+}
+
+//the first window decides for the runes before regexChunkSize, the second one for those before 2*regexChunkSize:
+//the matches around these cuts must be the same as on the whole message
+func TestRegexWindowBoundary(t *testing.T) {
+	const cut1, cut2, length = regexChunkSize, 2 * regexChunkSize, 3*regexChunkSize + 1000
+	tests := []struct {
+		name    string
+		pattern string
+		option  string
+		message string
+	}{
+		{"across the first cut", "~~", "", messageWith('a', length, map[int]string{cut1 - 1: "~~"})},
+		{"across the second cut", "~~", "", messageWith('a', length, map[int]string{cut2 - 1: "~~"})},
+		{"ending at the cut", "~~", "", messageWith('a', length, map[int]string{cut1 - 2: "~~"})},
+		{"starting at the cut", "~~", "", messageWith('a', length, map[int]string{cut1: "~~"})},
+		{"multibyte runes", "é~é", "", messageWith('é', length, map[int]string{cut1 - 1: "~", cut2: "~"})},
+		{"empty matches after a match ending at the cut", "b*", "replace=-",
+			messageWith('a', length, map[int]string{cut1 - 3: "bbb", cut2 - 1: "b"})},
+		{"empty matches across the cut", "b*", "replace=-", messageWith('a', length, nil)},
+		{"groups", "(x)(y)", "replace=$2$1", messageWith('a', length, map[int]string{cut1 - 1: "xy", cut2 - 1: "xy"})},
+		{"keep only", "[0-9]{2,5}", regexKeepOnly,
+			messageWith('a', length, map[int]string{cut1 - 3: "123456789", cut2 - 2: "42"})},
+		{"long bounded match", "x[^y]{1,1000}y", "",
+			messageWith('a', length, map[int]string{cut1 - 500: "x", cut1 + 400: "y"})},
+		{"short message", "~~", "", messageWith('a', 1000, map[int]string{999: "~"})},
+	}
+
+	for _, test := range tests {
+		args := make([]filter.Arg, 0, 1)
+		if test.option != "" {
+			args = append(args, filter.Arg{Text: test.option})
+		}
+		f, err := parseRegexFilter(test.pattern, args)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		cleaned, err := removeInterferences(test.message, f)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if expected := regexOnWhole(test.pattern, test.option, test.message); cleaned != expected {
+			t.Errorf("%s: got %d bytes, expected %d bytes", test.name, len(cleaned), len(expected))
+		}
+	}
+}
+
+//a match which goes on until the end of the window could go on in the following runes, so it is an error
+func TestRegexMatchReachingTheWindowEnd(t *testing.T) {
+	f, err := parseRegexFilter("x.*", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := messageWith('a', 3*regexChunkSize, map[int]string{10: "x"})
+	if _, err := removeInterferences(message, f); err == nil {
+		t.Error("expected an error for a match longer than the window")
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//a removal map is everything the filters removed from a message, so that the cleaned message can be audited: the
+//original message can be rebuilt from the cleaned one, and the offsets can be translated from one to the other. the
+//offsets are given both in bytes and in runes, an invalid byte counting as a rune
+
+type removalMap struct {
+	Length     int       `json:"length"` //of the original message, in bytes
+	RuneLength int       `json:"rune_length"`
+	Removals   []removal `json:"removals"`
+}
+
+//a run of consecutive runes of the original message removed by the same filter
+type removal struct {
+	Offset     int    `json:"offset"` //in the original message, in bytes
+	RuneOffset int    `json:"rune_offset"`
+	Text       string `json:"text"`
+	Filter     int    `json:"filter"` //starts at 1, as in error messages
+	Spec       string `json:"spec"`
+}
+
+//json can only hold valid UTF-8, so the text of a removal which isn't is also written as bytes, in base64, which
+//are what it is read back from. the text is then only there to be read by humans
+func (r removal) MarshalJSON() ([]byte, error) {
+	type plain removal //without these methods
+	out := struct {
+		plain
+		Bytes []byte `json:"bytes,omitempty"`
+	}{plain: plain(r)}
+	if !utf8.ValidString(r.Text) {
+		out.Text, out.Bytes = strings.ToValidUTF8(r.Text, "\ufffd"), []byte(r.Text)
+	}
+	return json.Marshal(out)
+}
+
+func (r *removal) UnmarshalJSON(data []byte) error {
+	type plain removal
+	var in struct {
+		plain
+		Bytes []byte `json:"bytes,omitempty"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*r = removal(in.plain)
+	if in.Bytes != nil {
+		r.Text = string(in.Bytes)
+	}
+	return nil
+}
+
+//the offset and the length of the removal, in runes or in bytes
+func (r removal) start(runes bool) int {
+	if runes {
+		return r.RuneOffset
+	}
+	return r.Offset
+}
+
+func (r removal) size(runes bool) int {
+	if runes {
+		return utf8.RuneCountInString(r.Text)
+	}
+	return len(r.Text)
+}
+
+func (m removalMap) length(runes bool) int {
+	if runes {
+		return m.RuneLength
+	}
+	return m.Length
+}
+
+//does what removeInterferences does and also returns what was removed
+func removeInterferencesWithMap(message string, filters ...filter.Filter) (string, removalMap, error) {
+	trace, err := traceInterferences(message, filters...)
+	if err != nil {
+		return "", removalMap{}, err
+	}
+	m, err := trace.removalMap(filters)
+	if err != nil {
+		return "", removalMap{}, err
+	}
+	return trace.Output, m, nil
+}
+
+//the text inserted by filters such as regex with replace would have to be removed from the cleaned message before
+//restoring it, which the map can't describe, so we refuse to build it when some of that text is left
+func (t messageTrace) removalMap(filters []filter.Filter) (removalMap, error) {
+	for _, tr := range t.outputRunes {
+		if tr.insertedBy != 0 {
+			return removalMap{}, fmt.Errorf("the message can't be restored as filter %d (%s) inserted text in it",
+				tr.insertedBy, filter.Spec(filters[tr.insertedBy-1]))
+		}
+	}
+
+	m := removalMap{Length: len(t.Input), RuneLength: len(t.runes), Removals: make([]removal, 0)}
+	for i, tr := range t.runes {
+		f := t.removedBy[i]
+		if f == 0 {
+			continue
+		}
+		if i == 0 || t.removedBy[i-1] != f { //a new removal starts
+			m.Removals = append(m.Removals, removal{Offset: tr.byteOffset, RuneOffset: tr.runeOffset, Filter: f,
+				Spec: filter.Spec(filters[f-1])})
+		}
+		last := &m.Removals[len(m.Removals)-1]
+		last.Text += t.Input[tr.byteOffset : tr.byteOffset+tr.size] //we copy the bytes, which may not be valid UTF-8
+	}
+	return m, nil
+}
+
+//checks that the removals are in order, don't overlap and fit in the original message, in bytes and in runes
+func (m removalMap) validate() error {
+	for _, runes := range []bool{false, true} {
+		end := 0
+		for i, r := range m.Removals {
+			if r.start(runes) < end || r.Text == "" {
+				return fmt.Errorf("removal %d at offset %d overlaps the previous one or is empty", i+1, r.start(runes))
+			}
+			end = r.start(runes) + r.size(runes)
+		}
+		if end > m.length(runes) {
+			return fmt.Errorf("the removals go beyond the length of the original message, %d", m.length(runes))
+		}
+	}
+	return nil
+}
+
+//rebuilds the original message from the cleaned one and the removal map which was returned with it
+func Unclean(cleaned string, m removalMap) (string, error) {
+	if err := m.validate(); err != nil {
+		return "", err
+	}
+	removed := 0
+	for _, r := range m.Removals {
+		removed += len(r.Text)
+	}
+	if len(cleaned)+removed != m.Length {
+		return "", fmt.Errorf("the cleaned message has %d bytes but the map expects %d", len(cleaned), m.Length-removed)
+	}
+
+	var original strings.Builder
+	original.Grow(m.Length)
+	position := 0 //in the cleaned message
+	for _, r := range m.Removals {
+		next := r.Offset - original.Len() + position //the removal comes after the kept bytes before it
+		original.WriteString(cleaned[position:next])
+		original.WriteString(r.Text)
+		position = next
+	}
+	original.WriteString(cleaned[position:])
+	return original.String(), nil
+}
+
+//returns the offset in the original message of the byte, or the rune, at offset in the cleaned message. the offset
+//just after the end of the cleaned message is allowed and gives the end of the original one
+func (m removalMap) toOriginal(offset int, runes bool) (int, error) {
+	cleanedLength := m.length(runes)
+	for _, r := range m.Removals {
+		cleanedLength -= r.size(runes)
+	}
+	if offset < 0 || offset > cleanedLength {
+		return 0, fmt.Errorf("offset %d is outside of the cleaned message [0, %d]", offset, cleanedLength)
+	}
+	for _, r := range m.Removals { //every removal before the byte shifts it
+		if r.start(runes) > offset {
+			break
+		}
+		offset += r.size(runes)
+	}
+	return offset, nil
+}
+
+//returns the offset in the cleaned message of the byte, or the rune, at offset in the original message. when it was
+//removed, we return the offset of the first one kept after it and the removal which removed it
+func (m removalMap) toCleaned(offset int, runes bool) (int, *removal, error) {
+	if offset < 0 || offset > m.length(runes) {
+		return 0, nil, fmt.Errorf("offset %d is outside of the original message [0, %d]", offset, m.length(runes))
+	}
+	//the first removal which doesn't end before offset
+	i := sort.Search(len(m.Removals), func(i int) bool {
+		return m.Removals[i].start(runes)+m.Removals[i].size(runes) > offset
+	})
+	cleaned := offset
+	for _, r := range m.Removals[:i] {
+		cleaned -= r.size(runes)
+	}
+	if i < len(m.Removals) && m.Removals[i].start(runes) <= offset {
+		return cleaned - (offset - m.Removals[i].start(runes)), &m.Removals[i], nil
+	}
+	return cleaned, nil, nil
+}
+
+func writeRemovalMap(path string, m removalMap) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(m); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func readRemovalMap(path string) (removalMap, error) {
+	var m removalMap
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err = json.Unmarshal(content, &m); err != nil {
+		return m, fmt.Errorf("%s: %v", path, err)
+	}
+	if err = m.validate(); err != nil {
+		return m, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+//translates offsets written original:N or cleaned:N, in bytes, or original-rune:N or cleaned-rune:N, in runes, and
+//prints one line for each of them
+func printTranslations(m removalMap, offsets []string) error {
+	for _, arg := range offsets {
+		i := strings.IndexByte(arg, ':')
+		if i < 0 {
+			return fmt.Errorf("offset %q must be written original:N, cleaned:N, original-rune:N or cleaned-rune:N", arg)
+		}
+		offset, err := strconv.Atoi(arg[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid offset %q", arg)
+		}
+		side, runes := arg[:i], strings.HasSuffix(arg[:i], "-rune")
+		unit := "byte"
+		if runes {
+			side, unit = strings.TrimSuffix(side, "-rune"), "rune"
+		}
+
+		switch side {
+		case "original":
+			cleaned, r, err := m.toCleaned(offset, runes)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("original %s %d -> cleaned %s %d", unit, offset, unit, cleaned)
+			if r != nil {
+				fmt.Printf(" (removed by filter %d, %s)", r.Filter, r.Spec)
+			}
+			fmt.Println()
+		case "cleaned":
+			original, err := m.toOriginal(offset, runes)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("cleaned %s %d -> original %s %d\n", unit, offset, unit, original)
+		default:
+			return fmt.Errorf("offset %q must be written original:N, cleaned:N, original-rune:N or cleaned-rune:N", arg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//the pipeline given to removeInterferences can be described with a small language, so that the cleaning rules can be
+//changed without recompiling: filters are separated by '|' and each of them is a name followed by its arguments,
+//which are either bare words or Go-like quoted strings. everything following a '#' outside of a string is a comment
+//	strip "." | between "*" "*" | between "(" ")" nested escape="\\" unbalanced=keep
+//raw strings between backquotes are handy for regular expressions, as backslashes aren't escapes in them
+//	regex `\d+` replace="#"
+//the filters are looked up by name in the registry of the filter package, -list-filters shows them
+
+//this is the pipeline of the original problem
+const defaultPipeline = `strip "." | between "*" "*"`
+
+type token struct {
+	text   string
+	pipe   bool //the token is a '|' rather than a word or a string
+	quoted bool //the token was written as a string, so it can't be an option
+	column int
+}
+
+func tokenize(spec string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(spec); {
+		switch c := spec[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#': //comments go to the end of the line
+			for i < len(spec) && spec[i] != '\n' {
+				i++
+			}
+		case c == '|':
+			tokens = append(tokens, token{text: "|", pipe: true, column: i + 1})
+			i++
+		case c == '"' || c == '`':
+			text, end, err := unquote(spec, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: text, quoted: true, column: i + 1})
+			i = end
+		default:
+			end := i
+			for end < len(spec) && !strings.ContainsRune(" \t\n\r|\"`#", rune(spec[end])) {
+				end++
+			}
+			text := spec[i:end]
+			//the value of an option such as escape="\\" can be a string
+			if strings.HasSuffix(text, "=") && end < len(spec) && (spec[end] == '"' || spec[end] == '`') {
+				value, valueEnd, err := unquote(spec, end)
+				if err != nil {
+					return nil, err
+				}
+				text, end = text+value, valueEnd
+			}
+			tokens = append(tokens, token{text: text, column: i + 1})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+//reads the string starting with the quote or backquote at spec[start] and returns it with the index following it
+func unquote(spec string, start int) (text string, end int, err error) {
+	quote := spec[start]
+	end = start + 1
+	for end < len(spec) && spec[end] != quote {
+		if spec[end] == '\\' && quote == '"' { //the escaped character can't end the string
+			end++
+		}
+		end++
+	}
+	if end >= len(spec) {
+		return "", 0, fmt.Errorf("column %d: unterminated string", start+1)
+	}
+	text, err = strconv.Unquote(spec[start : end+1])
+	if err != nil {
+		return "", 0, fmt.Errorf("column %d: invalid string %s", start+1, spec[start:end+1])
+	}
+	return text, end + 1, nil
+}
+
+//parses a pipeline into the filters removeInterferences expects, in the same order
+func parsePipeline(spec string) ([]filter.Filter, error) {
+	tokens, err := tokenize(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := make([]filter.Filter, 0)
+	for len(tokens) > 0 {
+		end := 0 //we look for the end of the current filter
+		for end < len(tokens) && !tokens[end].pipe {
+			end++
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("column %d: missing filter", tokens[0].column)
+		}
+		if end == len(tokens)-1 {
+			return nil, fmt.Errorf("column %d: missing filter after '|'", tokens[end].column)
+		}
+
+		args := make([]filter.Arg, 0, end-1)
+		for _, t := range tokens[1:end] {
+			args = append(args, filter.Arg{Text: t.text, Quoted: t.quoted})
+		}
+		f, err := filter.New(tokens[0].text, args)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", tokens[0].column, err)
+		}
+		filters = append(filters, f)
+
+		if end == len(tokens) {
+			break
+		}
+		tokens = tokens[end+1:]
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("the pipeline is empty")
+	}
+	return filters, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//forwards the runes kept by a stage of the pipeline to the following stage
+type forwarder struct {
+	next filter.Transformer
+	out  filter.Emitter //the emitter of the following stage
+}
+
+func (f forwarder) Keep(r rune) error {
+	return f.next.Feed(r, f.out)
+}
+
+func (f forwarder) Remove(r rune) error {
+	return nil
+}
+
+func (f forwarder) Insert(s string) error {
+	for len(s) > 0 {
+		r, size := filter.DecodeRune(s)
+		if err := f.next.Feed(r, f.out); err != nil {
+			return err
+		}
+		s = s[size:]
+	}
+	return nil
+}
+
+//writes the runes which went through the whole pipeline
+type writerEmitter struct {
+	w *bufio.Writer
+}
+
+func (e writerEmitter) Keep(r rune) error {
+	if err := filter.WriteRune(e.w, r); err != nil {
+		return outputError{err}
+	}
+	return nil
+}
+
+func (e writerEmitter) Remove(r rune) error {
+	return nil
+}
+
+func (e writerEmitter) Insert(s string) error {
+	if _, err := e.w.WriteString(s); err != nil {
+		return outputError{err}
+	}
+	return nil
+}
+
+//an error which happened while writing the cleaned message rather than in a filter
+type outputError struct {
+	error
+}
+
+//an error raised by one of the filters, the message says which one
+type filterError struct {
+	index int
+	spec  string
+	err   error
+}
+
+func (e *filterError) Error() string {
+	return fmt.Sprintf("filter %d (%s): %v", e.index+1, e.spec, e.err)
+}
+
+//as the runes kept by a stage are fed to the following one, the errors of the following stages go back through the
+//previous ones: this wrapper tags the errors created by its own transformer and lets the others through
+type namedTransformer struct {
+	filter.Transformer
+	index int
+	spec  string
+}
+
+func (t namedTransformer) Feed(r rune, out filter.Emitter) error {
+	return t.tag(t.Transformer.Feed(r, out))
+}
+
+func (t namedTransformer) End(out filter.Emitter) error {
+	return t.tag(t.Transformer.End(out))
+}
+
+func (t namedTransformer) tag(err error) error {
+	switch err.(type) {
+	case nil, *filterError, outputError:
+		return err
+	}
+	return &filterError{index: t.index, spec: t.spec, err: err}
+}
+
+//the transformers of a pipeline for a single message, each with the emitter its decisions go to
+type chain struct {
+	transformers []filter.Transformer
+	emitters     []filter.Emitter
+	out          filter.Emitter
+}
+
+func newChain(filters []filter.Filter, out filter.Emitter) *chain {
+	c := &chain{
+		transformers: make([]filter.Transformer, len(filters)),
+		emitters:     make([]filter.Emitter, len(filters)),
+		out:          out,
+	}
+	for i := len(filters) - 1; i >= 0; i-- { //we build it backwards as each stage needs the following one
+		c.transformers[i] = namedTransformer{Transformer: filters[i].NewTransformer(), index: i,
+			spec: filter.Spec(filters[i])}
+		c.emitters[i] = out
+		out = forwarder{next: c.transformers[i], out: out}
+	}
+	return c
+}
+
+func (c *chain) feed(r rune) error {
+	if len(c.transformers) == 0 {
+		return c.out.Keep(r)
+	}
+	return c.transformers[0].Feed(r, c.emitters[0])
+}
+
+//ends the stages in order, as ending one of them can send runes to the following ones
+func (c *chain) end() error {
+	for i, t := range c.transformers {
+		if err := t.End(c.emitters[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//cleans the message read from in and writes it to out, rune by rune
+func cleanStream(in io.Reader, out io.Writer, filters ...filter.Filter) error {
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	c := newChain(filters, writerEmitter{writer})
+
+	for {
+		r, err := filter.ReadRune(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = c.feed(r); err != nil {
+			return unwrapOutputError(err)
+		}
+	}
+	if err := c.end(); err != nil {
+		return unwrapOutputError(err)
+	}
+	return writer.Flush()
+}
+
+func unwrapOutputError(err error) error {
+	if o, ok := err.(outputError); ok {
+		return o.error
+	}
+	return err
+}
+
+//returns a reader of the cleaned message, so that cleaning can be chained with anything reading from an io.Reader.
+//the cleaning happens in another goroutine, which stops at the first error and returns it from Read
+func newCleaningReader(in io.Reader, filters ...filter.Filter) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(cleanStream(in, pw, filters...))
+	}()
+	return pr
+}
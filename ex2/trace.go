@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//the trace mode tells which filter removed what: the message goes through the filters one at a time and we record,
+//for each of them, the spans of the original message it removed. it keeps the whole message in memory, which is fine
+//as it is only meant to understand why a message was cleaned the way it was
+
+type messageTrace struct {
+	Input   string        `json:"input"`
+	Output  string        `json:"output"`
+	Filters []filterTrace `json:"filters"`
+	Error   string        `json:"error,omitempty"` //set when a filter rejected the message, which is then the last one
+
+	runes       []tracedRune //the runes of the original message
+	outputRunes []tracedRune //the runes of the cleaned message
+	removedBy   []int        //the index of the filter which removed each of them, starting at 1, or 0 if it was kept
+}
+
+type filterTrace struct {
+	Index    int            `json:"index"` //starts at 1, as in error messages
+	Spec     string         `json:"spec"`
+	Input    string         `json:"input"` //the message as this filter received it
+	Removed  []removedSpan  `json:"removed"`
+	Inserted []insertedText `json:"inserted,omitempty"`
+
+	kept       []bool         //whether each rune of the input was kept, nil when the filter rejected the message
+	insertions map[int]string //the text inserted before each rune of the input, len(kept) being the end
+}
+
+//a span of the original message removed by a filter. the ends are exclusive, and the span can contain runes removed
+//by previous filters when they were surrounded by runes removed by this one
+type removedSpan struct {
+	Text      string `json:"text"` //only the runes removed by this filter
+	ByteStart int    `json:"byte_start"`
+	ByteEnd   int    `json:"byte_end"`
+	RuneStart int    `json:"rune_start"`
+	RuneEnd   int    `json:"rune_end"`
+}
+
+//text inserted by a filter, such as the replacement of a regex. the offsets are those of the rune of the original
+//message it was inserted before
+type insertedText struct {
+	Text       string `json:"text"`
+	ByteOffset int    `json:"byte_offset"`
+	RuneOffset int    `json:"rune_offset"`
+}
+
+//remembers what a transformer did with each rune, in order
+type recordingEmitter struct {
+	kept       []bool
+	insertions map[int]string
+}
+
+func (e *recordingEmitter) Keep(r rune) error {
+	e.kept = append(e.kept, true)
+	return nil
+}
+
+func (e *recordingEmitter) Remove(r rune) error {
+	e.kept = append(e.kept, false)
+	return nil
+}
+
+func (e *recordingEmitter) Insert(s string) error {
+	e.insertions[len(e.kept)] += s
+	return nil
+}
+
+//a rune of the current message with where it was in the original one. inserted runes weren't there, so they have the
+//offsets of the rune they were inserted before
+type tracedRune struct {
+	r          rune
+	byteOffset int
+	runeOffset int
+	size       int //in bytes, which isn't the length of r when the message isn't valid UTF-8
+	insertedBy int //the index of the filter which inserted it, starting at 1, or 0 if it comes from the message
+}
+
+//does what removeInterferences does while recording what each filter removed. when a filter rejects the message, the
+//trace stops there and is returned with the error
+func traceInterferences(message string, filters ...filter.Filter) (messageTrace, error) {
+	trace := messageTrace{Input: message, Filters: make([]filterTrace, 0, len(filters))}
+	runes := make([]tracedRune, 0, len(message))
+	for byteOffset := 0; byteOffset < len(message); {
+		r, size := filter.DecodeRune(message[byteOffset:])
+		runes = append(runes, tracedRune{r: r, byteOffset: byteOffset, runeOffset: len(runes), size: size})
+		byteOffset += size
+	}
+	trace.runes = runes
+	trace.removedBy = make([]int, len(runes))
+
+	for i, f := range filters {
+		ft := filterTrace{Index: i + 1, Spec: filter.Spec(f), Input: tracedString(message, runes),
+			Removed: make([]removedSpan, 0)}
+		kept, insertions, err := runTransformer(f.NewTransformer(), runes)
+		if err != nil {
+			err = &filterError{index: i, spec: filter.Spec(f), err: err}
+			trace.Filters = append(trace.Filters, ft)
+			trace.Error = err.Error()
+			return trace, err
+		}
+
+		remaining := make([]tracedRune, 0, len(runes))
+		inSpan := false
+		for j := 0; j <= len(runes); j++ {
+			if text, ok := insertions[j]; ok {
+				anchor := tracedRune{byteOffset: len(message), runeOffset: len(trace.runes)} //the end of the message
+				if j < len(runes) {
+					anchor = runes[j]
+				}
+				ft.Inserted = append(ft.Inserted, insertedText{Text: text, ByteOffset: anchor.byteOffset,
+					RuneOffset: anchor.runeOffset})
+				for _, r := range text {
+					remaining = append(remaining, tracedRune{r: r, byteOffset: anchor.byteOffset,
+						runeOffset: anchor.runeOffset, insertedBy: i + 1})
+				}
+			}
+			if j == len(runes) {
+				break
+			}
+
+			tr := runes[j]
+			if kept[j] {
+				remaining = append(remaining, tr)
+				inSpan = false
+				continue
+			}
+			if tr.insertedBy != 0 { //it wasn't in the original message
+				continue
+			}
+			if !inSpan { //a new span starts
+				ft.Removed = append(ft.Removed, removedSpan{ByteStart: tr.byteOffset, RuneStart: tr.runeOffset})
+				inSpan = true
+			}
+			trace.removedBy[tr.runeOffset] = i + 1
+			span := &ft.Removed[len(ft.Removed)-1]
+			span.Text += message[tr.byteOffset : tr.byteOffset+tr.size]
+			span.ByteEnd = tr.byteOffset + tr.size
+			span.RuneEnd = tr.runeOffset + 1
+		}
+		ft.kept, ft.insertions = kept, insertions
+		trace.Filters = append(trace.Filters, ft)
+		runes = remaining
+	}
+	trace.Output, trace.outputRunes = tracedString(message, runes), runes
+	return trace, nil
+}
+
+//feeds the whole message to a single transformer and returns whether it kept each rune, and what it inserted
+func runTransformer(t filter.Transformer, runes []tracedRune) ([]bool, map[int]string, error) {
+	out := &recordingEmitter{kept: make([]bool, 0, len(runes)), insertions: make(map[int]string)}
+	for _, tr := range runes {
+		if err := t.Feed(tr.r, out); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := t.End(out); err != nil {
+		return nil, nil, err
+	}
+	if len(out.kept) != len(runes) { //the transformers must decide once for every rune
+		return nil, nil, fmt.Errorf("decided for %d runes out of %d", len(out.kept), len(runes))
+	}
+	return out.kept, out.insertions, nil
+}
+
+//rebuilds the text made of the runes of message: those which come from it are copied from its bytes, so that the
+//bytes which aren't valid UTF-8 stay as they were
+func tracedString(message string, runes []tracedRune) string {
+	var builder strings.Builder
+	for _, tr := range runes {
+		if tr.insertedBy == 0 {
+			builder.WriteString(message[tr.byteOffset : tr.byteOffset+tr.size])
+		} else {
+			filter.WriteRune(&builder, tr.r)
+		}
+	}
+	return builder.String()
+}
+
+//writes the trace in the given format, which is json or text. the text format shows what each filter received with
+//the runes it removed between [- and -] and the text it inserted between {+ and +}, as wdiff does
+func (t messageTrace) write(out io.Writer, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false) //the messages are full of < and >
+		return encoder.Encode(t)
+	case "text":
+		fmt.Fprintf(out, "input   %s\n", t.Input)
+		for _, ft := range t.Filters {
+			fmt.Fprintf(out, "%-7d %s\n        %s\n", ft.Index, ft.Spec, ft.annotate())
+			for _, span := range ft.Removed {
+				fmt.Fprintf(out, "        removed %q at bytes [%d, %d) runes [%d, %d)\n",
+					span.Text, span.ByteStart, span.ByteEnd, span.RuneStart, span.RuneEnd)
+			}
+			for _, inserted := range ft.Inserted {
+				fmt.Fprintf(out, "        inserted %q at byte %d rune %d\n",
+					inserted.Text, inserted.ByteOffset, inserted.RuneOffset)
+			}
+		}
+		if t.Error != "" {
+			_, err := fmt.Fprintf(out, "error   %s\n", t.Error)
+			return err
+		}
+		_, err := fmt.Fprintf(out, "output  %s\n", t.Output)
+		return err
+	}
+	return fmt.Errorf("unknown trace format %q, expected json or text", format)
+}
+
+//returns the input of the filter with the runes it removed between [- and -] and the text it inserted between {+ and
+//+}. when the filter rejected the message, nothing is marked
+func (ft filterTrace) annotate() string {
+	var builder strings.Builder
+	i := 0
+	for input := ft.Input; len(input) > 0; {
+		r, size := filter.DecodeRune(input)
+		input = input[size:]
+		if text, ok := ft.insertions[i]; ok {
+			builder.WriteString("{+" + text + "+}")
+		}
+		removed := ft.kept != nil && !ft.kept[i]
+		if removed && (i == 0 || ft.kept[i-1]) {
+			builder.WriteString("[-")
+		}
+		filter.WriteRune(&builder, r)
+		if removed && (i == len(ft.kept)-1 || ft.kept[i+1]) {
+			builder.WriteString("-]")
+		}
+		i++
+	}
+	if text, ok := ft.insertions[i]; ok {
+		builder.WriteString("{+" + text + "+}")
+	}
+	return builder.String()
+}
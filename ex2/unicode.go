@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/Neirpyc/Prologin2019/ex2/filter"
+)
+
+//the filters work on runes, but what a reader sees as a single character can be made of several of them: a letter
+//followed by combining accents, an emoji with a skin tone or joined to another one. with the grapheme option, the
+//filters handle these grapheme clusters as a whole, so that removing "e" doesn't leave an accent alone and a
+//delimiter followed by a combining accent isn't a delimiter. we don't need the full segmentation rules of Unicode
+//for that, only to know which runes continue the cluster before them
+
+const zeroWidthJoiner = '\u200d'
+
+//returns whether r belongs to the same grapheme cluster as previous, the rune before it
+func extendsCluster(previous, r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc): //combining marks, including variation selectors
+		return true
+	case r == zeroWidthJoiner, previous == zeroWidthJoiner:
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: //emoji skin tones
+		return true
+	case isRegionalIndicator(previous) && isRegionalIndicator(r): //flags, we don't check they go by pairs
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+//splits s in grapheme clusters
+func splitClusters(s string) []string {
+	clusters := make([]string, 0)
+	var previous rune = -1
+	for _, r := range s {
+		if len(clusters) > 0 && extendsCluster(previous, r) {
+			clusters[len(clusters)-1] += string(r)
+		} else {
+			clusters = append(clusters, string(r))
+		}
+		previous = r
+	}
+	return clusters
+}
+
+//a filter which decides for each grapheme cluster alone: its transformer waits for the end of a cluster, then keeps
+//or removes all of its runes
+type clusterFilter func(cluster []rune) (keep bool)
+
+type clusterTransformer struct {
+	decide  clusterFilter
+	cluster []rune
+}
+
+func (t *clusterTransformer) Feed(r rune, out filter.Emitter) error {
+	if len(t.cluster) > 0 && !extendsCluster(t.cluster[len(t.cluster)-1], r) {
+		if err := t.flush(out); err != nil {
+			return err
+		}
+	}
+	t.cluster = append(t.cluster, r)
+	return nil
+}
+
+func (t *clusterTransformer) End(out filter.Emitter) error {
+	return t.flush(out)
+}
+
+func (t *clusterTransformer) flush(out filter.Emitter) error {
+	if len(t.cluster) == 0 { //the message is empty
+		return nil
+	}
+	keep := t.decide(t.cluster)
+	for _, r := range t.cluster {
+		var err error
+		if keep {
+			err = out.Keep(r)
+		} else {
+			err = out.Remove(r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.cluster = t.cluster[:0]
+	return nil
+}
+
+//the names which can be used for the categories, besides those of unicode.Categories such as "P" or "Cc"
+var categoryAliases = map[string]string{
+	"letter":      "L",
+	"mark":        "M",
+	"number":      "N",
+	"punctuation": "P",
+	"symbol":      "S",
+	"separator":   "Z",
+	"control":     "Cc",
+	"format":      "Cf",
+}
+
+func init() {
+	filter.Register(filter.Kind{
+		Name:  "strip-category",
+		Usage: `"category"... [grapheme]`,
+		Description: "removes the characters of the Unicode categories, such as P or punctuation, Cc or control, " +
+			"S or symbol",
+		Parse: func(args []filter.Arg) (filter.Filter, error) {
+			f := stripCategoryFilter{}
+			for _, arg := range args {
+				if arg.Text == "grapheme" && !arg.Quoted {
+					f.grapheme = true
+					continue
+				}
+				name := arg.Text
+				if alias, ok := categoryAliases[name]; ok {
+					name = alias
+				}
+				table, ok := unicode.Categories[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown Unicode category %q", arg.Text)
+				}
+				f.categories = append(f.categories, arg.Text)
+				f.tables = append(f.tables, table)
+			}
+			if len(f.categories) == 0 {
+				return nil, fmt.Errorf("strip-category needs at least one category")
+			}
+			return f, nil
+		},
+		Candidates: func(examples []filter.Example) []filter.Filter { //the categories of the runes which disappear
+			candidates := make([]filter.Filter, 0)
+			seen := make(map[string]bool)
+			for _, r := range removedRunes(examples, learnedRunes) {
+				category := runeCategory(r)
+				if category == "" {
+					continue
+				}
+				for _, name := range []string{category, category[:1]} { //such as Po, then the whole of P
+					if !seen[name] {
+						seen[name] = true
+						candidates = append(candidates, stripCategoryFilter{categories: []string{name},
+							tables: []*unicode.RangeTable{unicode.Categories[name]}})
+					}
+				}
+			}
+			return candidates
+		},
+	})
+}
+
+//returns the category of r, such as Po or Cc, or "" when it has none as it isn't assigned
+func runeCategory(r rune) string {
+	for name, table := range unicode.Categories {
+		if len(name) == 2 && unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+type stripCategoryFilter struct {
+	categories []string //as they were written
+	tables     []*unicode.RangeTable
+	grapheme   bool //a cluster is removed when its first rune is in the categories
+}
+
+func (f stripCategoryFilter) Name() string {
+	return "strip-category"
+}
+
+func (f stripCategoryFilter) Description() string {
+	return describe("strip-category")
+}
+
+func (f stripCategoryFilter) Params() []filter.Param {
+	params := make([]filter.Param, 0, len(f.categories)+1)
+	for _, category := range f.categories {
+		params = append(params, filter.Param{Name: "category", Value: category, Positional: true})
+	}
+	if f.grapheme {
+		params = append(params, filter.Param{Name: "grapheme", Flag: true})
+	}
+	return params
+}
+
+func (f stripCategoryFilter) NewTransformer() filter.Transformer {
+	if f.grapheme {
+		return &clusterTransformer{decide: func(cluster []rune) bool {
+			return !unicode.In(cluster[0], f.tables...)
+		}}
+	}
+	return filter.RuneFilter(func(r rune) bool {
+		return !unicode.In(r, f.tables...)
+	})
+}
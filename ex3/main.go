@@ -2,8 +2,11 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"math"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 )
@@ -11,65 +14,219 @@ import (
 const intMax = int(^uint(0) >> 1)
 
 func main() {
+	all := flag.Bool("all", false, "find every shortest window rather than the first one, implies -show")
+	show := flag.Bool("show", false, "print the indices and the prices of the ores of the window instead of its "+
+		"length, the first ore being 0")
+	solver := flag.String("solver", solverAuto, "algorithm to use: auto, which uses two-pointers when every cost is "+
+		"positive or zero and prefix-sums otherwise, two-pointers or prefix-sums")
+	queries := flag.Bool("queries", false, "answer many target prices against the same ores: the third line is the "+
+		"number of queries, followed by their target prices, and an answer is printed for each of them in order")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of queries answered at the same time with -queries")
+	flag.Parse()
+	if *queries && *solver != solverAuto {
+		exitOnError(fmt.Errorf("-solver can't be used with -queries, which has its own index"))
+	}
+	if *workers < 1 {
+		exitOnError(fmt.Errorf("-workers must be at least 1, got %d", *workers))
+	}
+
 	var input Input
-	input = getAndParseInput() // we read the input and store it in  a struct
-	fmt.Println(solve(input))  //we compute and print the answer
+	input, targetPrices := getAndParseInput(*queries) // we read the input and store it in  a struct
+	if *queries {
+		answerQueries(input, targetPrices, *show || *all, *all, *workers)
+		return
+	}
+	windows, err := solveWith(*solver, input, *all) //we compute the answer
+	exitOnError(err)
+	if *show || *all {
+		printWindows(input, windows)
+	} else if len(windows) == 0 {
+		fmt.Println(-1) //nothing costs exactly the price
+	} else {
+		fmt.Println(windows[0].length()) //and print it
+	}
+}
+
+//a window is the ores we buy, which are consecutive: from start to end, both included. buying nothing is the window
+//whose end is just before its start
+type window struct {
+	start int
+	end   int
+}
+
+func (w window) length() int {
+	return w.end - w.start + 1
+}
+
+//the solvers call this for every window costing the wanted price they find, in order: it keeps the shortest ones
+//found so far, only the first of them unless all is true
+func keepShortest(windows *[]window, smallest *int, w window, all bool) {
+	if w.length() < *smallest { //we update the current smallest number of elements
+		*smallest = w.length()
+		*windows = (*windows)[:0]
+	}
+	if w.length() == *smallest && (all || len(*windows) == 0) {
+		*windows = append(*windows, w)
+	}
 }
 
+//prints each window on its own line, with its length, its indices and the prices of its ores
+func printWindows(input Input, windows []window) {
+	if len(windows) == 0 {
+		fmt.Println("no window costs", input.targetPrice)
+		return
+	}
+	for _, w := range windows {
+		if w.length() == 0 {
+			fmt.Println("nothing to buy")
+			continue
+		}
+		prices := make([]string, 0, w.length())
+		for _, cost := range input.mineralsCosts[w.start : w.end+1] {
+			prices = append(prices, strconv.FormatInt(cost, 10))
+		}
+		ores := "ores"
+		if w.length() == 1 {
+			ores = "ore"
+		}
+		fmt.Printf("%d %s from %d to %d: %s\n", w.length(), ores, w.start, w.end, strings.Join(prices, " "))
+	}
+}
+
+//builds the index once and prints the answer of each query, in the same way as for a single target price
+func answerQueries(input Input, targetPrices []int64, show, all bool, workers int) {
+	index := newWindowIndex(input.mineralsCosts)
+	answers := index.queryAll(targetPrices, all, workers)
+	for q, windows := range answers {
+		if show {
+			fmt.Printf("target %d:\n", targetPrices[q])
+			input.targetPrice = targetPrices[q]
+			printWindows(input, windows)
+		} else if len(windows) == 0 {
+			fmt.Println(-1)
+		} else {
+			fmt.Println(windows[0].length())
+		}
+	}
+}
+
+//the costs can be negative, for the ores which come with a rebate. the sum of the first costs must fit in an int64,
+//for any number of them, which getAndParseInput checks
 type Input struct {
 	oreCount      int
-	mineralsCosts []uint8
-	targetPrice   int
+	mineralsCosts []int64
+	targetPrice   int64
 }
 
-func getAndParseInput() (input Input) {
+//with queries, the third line is the number of queries and the target prices follow it, on as many lines as needed.
+//they are returned besides the input, whose target price is then left at 0
+func getAndParseInput(queries bool) (input Input, targetPrices []int64) {
 	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Buffer(make([]byte, 100), 4000000)
+	scanner.Buffer(make([]byte, 100), 64000000)
 
 	var n int
 	scanner.Scan()
-	n, _ = strconv.Atoi(scanner.Text())
-	priceList := make([]uint8, n)
-	scanner.Scan()
-	for i, iValue := range strings.SplitN(scanner.Text(), " ", n) {
-		v, _ := strconv.Atoi(iValue)
-		priceList[i] = uint8(v)
+	n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil || n < 0 {
+		exitOnError(fmt.Errorf("invalid number of ores %q", scanner.Text()))
 	}
-	var b int
+	priceList := make([]int64, n)
 	scanner.Scan()
-	b, _ = strconv.Atoi(scanner.Text())
-
+	values := strings.Fields(scanner.Text())
+	if len(values) != n {
+		exitOnError(fmt.Errorf("expected %d prices, got %d", n, len(values)))
+	}
+	var sum int64
+	for i, iValue := range values {
+		priceList[i], err = strconv.ParseInt(iValue, 10, 64) //we used to wrap the prices above 255
+		if err != nil {
+			exitOnError(fmt.Errorf("invalid price %q of ore %d", iValue, i))
+		}
+		if addOverflows(sum, priceList[i]) { //the solvers rely on these sums
+			exitOnError(fmt.Errorf("the sum of the prices up to ore %d doesn't fit in an int64", i))
+		}
+		sum += priceList[i]
+	}
 	input.oreCount = n
 	input.mineralsCosts = priceList
+	if queries {
+		return input, getQueries(scanner)
+	}
+
+	var b int64
+	scanner.Scan()
+	b, err = strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+	if err != nil {
+		exitOnError(fmt.Errorf("invalid target price %q", scanner.Text()))
+	}
+
 	input.targetPrice = b
 
-	return input
+	return input, nil
+}
+
+func getQueries(scanner *bufio.Scanner) []int64 {
+	scanner.Scan()
+	count, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil || count < 0 {
+		exitOnError(fmt.Errorf("invalid number of queries %q", scanner.Text()))
+	}
+	targetPrices := make([]int64, 0, count)
+	for len(targetPrices) < count && scanner.Scan() {
+		for _, value := range strings.Fields(scanner.Text()) {
+			b, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				exitOnError(fmt.Errorf("invalid target price %q of query %d", value, len(targetPrices)))
+			}
+			targetPrices = append(targetPrices, b)
+		}
+	}
+	exitOnError(scanner.Err())
+	if len(targetPrices) != count {
+		exitOnError(fmt.Errorf("expected %d target prices, got %d", count, len(targetPrices)))
+	}
+	return targetPrices
+}
+
+func addOverflows(a, b int64) bool {
+	return b > 0 && a > math.MaxInt64-b || b < 0 && a < math.MinInt64-b
 }
 
 //this algorithm starts from the price of the first element, adds a new one if this is cheaper than the wanted price
-//and removes on if it is more expensive.
-func solve(input Input) int {
+//and removes on if it is more expensive. it only works when no cost is negative, as adding an ore must never make
+//the sum cheaper.
+//it returns the shortest windows which cost exactly the wanted price, in order: only the first one unless all is
+//true, and none if there is no such window
+func solve(input Input, all bool) []window {
 	if input.targetPrice == 0 { //if we want to pay 0, we just buy nothing
-		return 0
+		return []window{{start: 0, end: -1}}
+	}
+	if input.oreCount == 0 || input.targetPrice < 0 { //there is nothing to buy, or nothing this cheap
+		return nil
 	}
 	firstItemIndex := 0
 	lastItemIndex := 0
-	currentSum := int(input.mineralsCosts[0])
+	currentSum := input.mineralsCosts[0]
 
 	smallestSoFar := intMax
+	windows := make([]window, 0)
 
 	for true {
-		if currentSum == input.targetPrice && lastItemIndex-firstItemIndex < smallestSoFar { //if the sum is the wanted
-		// one
-			smallestSoFar = lastItemIndex - firstItemIndex //we update the current smallest number of elements
+		if currentSum == input.targetPrice { //if the sum is the wanted one
+			keepShortest(&windows, &smallestSoFar, window{start: firstItemIndex, end: lastItemIndex}, all)
+			currentSum -= input.mineralsCosts[firstItemIndex] //and we look for the next one
+			firstItemIndex++
+			if firstItemIndex >= input.oreCount {
+				break
+			}
 		} else if currentSum < input.targetPrice { //if the sum is cheaper
 			lastItemIndex++ //we add an item
 			if lastItemIndex >= input.oreCount { //prevent out of bounds read
 				break
 			}
-			currentSum += int(input.mineralsCosts[lastItemIndex]) //and update the sum
+			currentSum += input.mineralsCosts[lastItemIndex] //and update the sum
 		} else { //if it is less
-			currentSum -= int(input.mineralsCosts[firstItemIndex]) //we remove an element
+			currentSum -= input.mineralsCosts[firstItemIndex] //we remove an element
 			firstItemIndex++
 			if firstItemIndex >= input.oreCount { //and prevent out of bounds read next time
 				break
@@ -77,9 +234,13 @@ func solve(input Input) int {
 		}
 	}
 
-	if smallestSoFar == intMax { //return -1 if nothing is foundo
-		return -1
-	}
+	return windows
+}
 
-	return smallestSoFar + 1
+//prints err and stops the program if it isn't nil
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ex3:", err)
+		os.Exit(1)
+	}
 }
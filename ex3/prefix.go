@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	solverAuto        = "auto"
+	solverTwoPointers = "two-pointers"
+	solverPrefixSums  = "prefix-sums"
+)
+
+//returns the windows found by the given solver. the two pointers are faster but need costs which are never negative,
+//so auto only uses them when it is the case
+func solveWith(solver string, input Input, all bool) ([]window, error) {
+	switch solver {
+	case solverAuto:
+		if allNonNegative(input.mineralsCosts) {
+			return solve(input, all), nil
+		}
+		return solvePrefixSums(input, all), nil
+	case solverTwoPointers:
+		if !allNonNegative(input.mineralsCosts) {
+			return nil, fmt.Errorf("the %s solver can't be used with negative costs", solverTwoPointers)
+		}
+		return solve(input, all), nil
+	case solverPrefixSums:
+		return solvePrefixSums(input, all), nil
+	}
+	return nil, fmt.Errorf("unknown solver %q, expected %s, %s or %s", solver, solverAuto, solverTwoPointers,
+		solverPrefixSums)
+}
+
+func allNonNegative(costs []int64) bool {
+	for _, cost := range costs {
+		if cost < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//this algorithm works with any cost. the window from start to end costs prefix[end+1] - prefix[start], where
+//prefix[i] is the sum of the i first costs, so for each end we look for the latest start whose prefix is
+//prefix[end+1] - targetPrice. we remember the latest index of each prefix in a map as we go, which gives the
+//shortest window ending at each ore in constant time. when prefix - targetPrice doesn't fit in an int64, no prefix can
+//be equal to it.
+//it returns the same windows as solve, in the same order
+func solvePrefixSums(input Input, all bool) []window {
+	if input.targetPrice == 0 { //if we want to pay 0, we just buy nothing
+		return []window{{start: 0, end: -1}}
+	}
+
+	latest := make(map[int64]int, len(input.mineralsCosts)+1) //the latest index of each prefix
+	latest[0] = 0
+	var prefix int64
+	smallestSoFar := intMax
+	windows := make([]window, 0)
+
+	for end, cost := range input.mineralsCosts {
+		prefix += cost
+		if !subOverflows(prefix, input.targetPrice) {
+			if start, ok := latest[prefix-input.targetPrice]; ok {
+				keepShortest(&windows, &smallestSoFar, window{start: start, end: end}, all)
+			}
+		}
+		latest[prefix] = end + 1
+	}
+	return windows
+}
+
+func subOverflows(a, b int64) bool {
+	return b < 0 && a > math.MaxInt64+b || b > 0 && a < math.MinInt64+b
+}
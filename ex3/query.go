@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+//when many target prices are asked against the same ores, we build an index once: the prefix sums, where prefix[i]
+//is the sum of the i first costs, grouped by value. a window from start to end costs targetPrice exactly when
+//prefix[end+1] - prefix[start] = targetPrice, so a query only has to pair each group with the group whose value is
+//targetPrice more. as the values are sorted, both groups are found by walking the values once with two indices, and
+//as the indices of each group are sorted too, pairing two groups is a merge. a query never hashes nor allocates
+//anything but its answer, so thousands of them run quickly on many goroutines over the same read-only index
+type windowIndex struct {
+	values    []int64 //the distinct prefix sums, in increasing order
+	starts    []int   //the indices of values[k] are positions[starts[k]:starts[k+1]]
+	positions []int   //in increasing order within each group
+}
+
+func newWindowIndex(costs []int64) *windowIndex {
+	prefix := make([]int64, len(costs)+1)
+	for i, cost := range costs {
+		prefix[i+1] = prefix[i] + cost
+	}
+	positions := make([]int, len(prefix))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(a, b int) bool { //stable, so that the indices of a group stay in order
+		return prefix[positions[a]] < prefix[positions[b]]
+	})
+
+	index := &windowIndex{positions: positions}
+	for i, p := range positions {
+		if i == 0 || prefix[p] != prefix[positions[i-1]] {
+			index.values = append(index.values, prefix[p])
+			index.starts = append(index.starts, i)
+		}
+	}
+	index.starts = append(index.starts, len(positions))
+	return index
+}
+
+func (x *windowIndex) group(k int) []int {
+	return x.positions[x.starts[k]:x.starts[k+1]]
+}
+
+//returns the same windows as solve and solvePrefixSums: the shortest windows costing exactly targetPrice, ordered by
+//their start, only the first one unless all is true
+func (x *windowIndex) query(targetPrice int64, all bool) []window {
+	if targetPrice == 0 { //if we want to pay 0, we just buy nothing
+		return []window{{start: 0, end: -1}}
+	}
+
+	smallestSoFar := intMax
+	windows := make([]window, 0)
+	k2 := 0 //the group whose value is values[k] + targetPrice, or the first one above
+	for k, value := range x.values {
+		if addOverflows(value, targetPrice) { //no prefix sum can be equal to it
+			if targetPrice > 0 { //nor to those of the following values
+				break
+			}
+			continue
+		}
+		for k2 < len(x.values) && x.values[k2] < value+targetPrice {
+			k2++
+		}
+		if k2 == len(x.values) {
+			break
+		}
+		if x.values[k2] != value+targetPrice {
+			continue
+		}
+
+		//for each end of the window, the closest start before it
+		starts, ends := x.group(k), x.group(k2)
+		s := 0
+		for _, end := range ends {
+			for s < len(starts) && starts[s] < end {
+				s++
+			}
+			if s == 0 { //every start is after this end
+				continue
+			}
+			//we keep all of them as they aren't found in order
+			keepShortest(&windows, &smallestSoFar, window{start: starts[s-1], end: end - 1}, true)
+		}
+	}
+
+	sort.Slice(windows, func(i, j int) bool { //the groups are in the order of their values, not of the ores
+		return windows[i].start < windows[j].start
+	})
+	if !all && len(windows) > 1 {
+		windows = windows[:1]
+	}
+	return windows
+}
+
+//answers every query with workers goroutines, the answers being in the order of the queries
+func (x *windowIndex) queryAll(targetPrices []int64, all bool, workers int) [][]window {
+	answers := make([][]window, len(targetPrices))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range next {
+				answers[q] = x.query(targetPrices[q], all)
+			}
+		}()
+	}
+	for q := range targetPrices {
+		next <- q
+	}
+	close(next)
+	wg.Wait()
+	return answers
+}